@@ -0,0 +1,240 @@
+// The command line client of Snippetbox, see usage. Build it with
+//
+//	go build -o snippetbox-cli ./cmd/cli
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"snippetbox/pkg/client"
+	"snippetbox/pkg/highlight"
+	"snippetbox/pkg/models"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Usage: snippetbox-cli [flags] <command> [arguments]
+
+Commands:
+  paste [flags] [file]   Creates a snippet from the file, or from stdin, and prints its URL
+  get <id>               Prints the content of the snippet
+  ls [flags]             Lists the public snippets, newest first
+  rm <id>...             Moves the snippets to the trash
+
+The token, server and certificate are read from the config file. The
+%s, %s and %s environment variables override it,
+and the flags override both.
+Run "snippetbox-cli <command> -h" for the flags of a command.
+
+Flags:
+`
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintf(os.Stderr, "snippetbox-cli: %s\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	defaultConfig, _ := client.DefaultConfigPath()
+	flags := flag.NewFlagSet("snippetbox-cli", flag.ContinueOnError)
+	configPath := flags.String("config", defaultConfig, "JSON config file with the server, token and ca_file")
+	server := flags.String("server", "", "Address of the server, "+client.DefaultServer+" by default")
+	token := flags.String("token", "", "Personal API token, created on the settings page")
+	caFile := flags.String("ca", "", "PEM certificate to trust, e.g. the tls/cert.pem of tls/generate_cert.go")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), usage, client.EnvToken, client.EnvServer, client.EnvCAFile)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(*configPath, *configPath == defaultConfig)
+	if err != nil {
+		return err
+	}
+	config.ApplyEnv()
+	for setting, value := range map[*string]string{&config.Server: *server, &config.Token: *token, &config.CAFile: *caFile} {
+		if value != "" {
+			*setting = value
+		}
+	}
+	c, err := config.Client()
+	if err != nil {
+		return err
+	}
+
+	command, args := flags.Arg(0), flags.Args()
+	if len(args) > 0 {
+		args = args[1:]
+	}
+	switch command {
+	case "paste":
+		return paste(ctx, c, args, stdin, stdout)
+	case "get":
+		return get(ctx, c, args, stdout)
+	case "ls":
+		return list(ctx, c, args, stdout)
+	case "rm":
+		return remove(ctx, c, args)
+	case "":
+		flags.Usage()
+		return flag.ErrHelp
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// Reads the config file. The default one doesn't have to exist.
+func loadConfig(path string, optional bool) (*client.Config, error) {
+	if path == "" {
+		return &client.Config{}, nil
+	}
+	config, err := client.LoadConfig(path)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		return &client.Config{}, nil
+	}
+	return config, err
+}
+
+// The flags mirror the fields of the snippet form.
+func paste(ctx context.Context, c *client.Client, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("paste", flag.ContinueOnError)
+	title := flags.String("title", "", "Title of the snippet, the file name by default")
+	language := flags.String("language", "", "Language to highlight, guessed from the file extension or the content when empty")
+	visibility := flags.String("visibility", "", "public, unlisted or private")
+	password := flags.String("password", "", "Password asked before showing the snippet")
+	expires := flags.String("expires", "7", "Days until the snippet expires (1, 7 or 365), or never")
+	expiresIn := flags.Duration("expires-in", 0, "Time until the snippet expires, e.g. 36h, instead of -expires")
+	expiresAt := flags.String("expires-at", "", "UTC time the snippet expires, formatted as 2006-01-02T15:04, instead of -expires")
+	views := flags.Int("views", 0, "Deletes the snippet after that many views")
+	tags := flags.String("tags", "", "Comma-separated tags")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return errors.New("paste takes at most one file")
+	}
+
+	name, r := "stdin", stdin
+	if file := flags.Arg(0); file != "" && file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		name, r = filepath.Base(file), f
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	req := &client.SnippetRequest{
+		Title:      *title,
+		Content:    string(content),
+		Language:   *language,
+		Visibility: *visibility,
+		Password:   *password,
+		Expires:    *expires,
+		Views:      *views,
+	}
+	if req.Title == "" {
+		req.Title = name
+	}
+	if req.Language == "" {
+		req.Language = highlight.ForFile(name)
+	}
+	if *expiresIn != 0 {
+		req.Expires, req.ExpiresIn = "custom", expiresIn.String()
+	} else if *expiresAt != "" {
+		req.Expires, req.ExpiresAt = "at", *expiresAt
+	}
+	for _, tag := range strings.Split(*tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			req.Tags = append(req.Tags, tag)
+		}
+	}
+
+	snippet, err := c.Create(ctx, req)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, c.URL(snippet))
+	return nil
+}
+
+func get(ctx context.Context, c *client.Client, args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("get takes the ID of a snippet")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid ID %q", args[0])
+	}
+	snippet, err := c.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(stdout, snippet.Content)
+	return err
+}
+
+func list(ctx context.Context, c *client.Client, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("ls", flag.ContinueOnError)
+	author := flags.Int("author", 0, "Only the snippets of this user ID")
+	limit := flags.Int("limit", 0, "Number of snippets per page")
+	after := flags.String("after", "", "Cursor of the next page, printed after the previous one")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	page, err := c.List(ctx, client.ListOptions{Author: *author, Limit: *limit, After: *after})
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTITLE\tAUTHOR\tCREATED\tEXPIRES")
+	for _, s := range page.Snippets {
+		expires := s.Expires.UTC().Format(time.RFC3339)
+		if !s.Expires.Before(models.Never) {
+			expires = "never"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", s.ID, s.Title, s.Author, s.Created.UTC().Format(time.RFC3339), expires)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if page.Next != "" {
+		fmt.Fprintf(stdout, "\nNext page: -after %s\n", page.Next)
+	}
+	return nil
+}
+
+func remove(ctx context.Context, c *client.Client, args []string) error {
+	if len(args) == 0 {
+		return errors.New("rm takes the IDs of the snippets")
+	}
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid ID %q", arg)
+		}
+		if err := c.Delete(ctx, id); err != nil {
+			return fmt.Errorf("snippet %d: %w", id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,406 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bmizerany/pat"
+	"github.com/justinas/alice"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"snippetbox/pkg/forms"
+	"snippetbox/pkg/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The largest request body the API reads, which is plenty for the 100
+// characters of a title and the content of any reasonable snippet.
+const maxAPIBody = 1 << 20
+
+// The body of the JSON errors. Fields holds the messages per field when a
+// snippet doesn't validate, see validateSnippetForm.
+type apiError struct {
+	Error  string              `json:"error"`
+	Fields map[string][]string `json:"fields,omitempty"`
+}
+
+// The answer of GET /api/v1/snippets. Next is the cursor to pass as the
+// after query parameter to get the next page, empty on the last one.
+type apiSnippetPage struct {
+	Snippets []*models.Snippet `json:"snippets"`
+	Next     string            `json:"next,omitempty"`
+}
+
+// The body of the create and update requests. The fields are those of the
+// HTML forms so that validateSnippetForm checks both the same way: expires
+// is a number of days, "custom" for expires_in, "at" for expires_at or
+// "never". Updates ignore the password and the views. The optional fields
+// are marked omitempty for the OpenAPI document, see structSchema.
+type apiSnippetRequest struct {
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	Language   string   `json:"language,omitempty"`
+	Visibility string   `json:"visibility,omitempty"`
+	Password   string   `json:"password,omitempty"`
+	Expires    string   `json:"expires"`
+	ExpiresIn  string   `json:"expires_in,omitempty"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	Views      int      `json:"views,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+// Returns the request as the values of an HTML form.
+func (req *apiSnippetRequest) form() *forms.Form {
+	values := url.Values{}
+	for field, value := range map[string]string{
+		"title":      req.Title,
+		"content":    req.Content,
+		"language":   req.Language,
+		"visibility": req.Visibility,
+		"password":   req.Password,
+		"expires":    req.Expires,
+		"expires_in": req.ExpiresIn,
+		"expires_at": req.ExpiresAt,
+		"tags":       strings.Join(req.Tags, ", "),
+	} {
+		if value != "" {
+			values.Set(field, value)
+		}
+	}
+	if req.Views != 0 {
+		values.Set("views", strconv.Itoa(req.Views))
+	}
+	return forms.New(values)
+}
+
+// Adds the JSON API under /api/v1 to mux. Scripts authenticate with an API
+// token, see authenticateToken, and browsers may read with their session
+// like the pages do. It skips noSurf, so the requests that change snippets
+// need a token, see requireAPIToken.
+func (app *Application) createAPIRoutes(mux *pat.PatternServeMux) {
+	apiMiddleware := alice.New(app.Session.Enable, app.authenticate, app.authenticateToken, app.requireJSON)
+	userMiddleware := apiMiddleware.Append(app.requireAPIUser)
+	tokenMiddleware := apiMiddleware.Append(app.requireAPIToken)
+	ownerMiddleware := tokenMiddleware.Append(app.loadAPISnippet, app.requireAPISnippetOwner)
+
+	mux.Get("/api/v1/snippets", apiMiddleware.ThenFunc(app.apiListSnippets))
+	mux.Post("/api/v1/snippets", tokenMiddleware.ThenFunc(app.apiCreateSnippet))
+	mux.Get("/api/v1/snippets/:id", apiMiddleware.Append(app.loadAPISnippet).ThenFunc(app.apiGetSnippet))
+	mux.Put("/api/v1/snippets/:id", ownerMiddleware.ThenFunc(app.apiUpdateSnippet))
+	mux.Del("/api/v1/snippets/:id", ownerMiddleware.ThenFunc(app.apiDeleteSnippet))
+	mux.Get("/api/v1/user", userMiddleware.ThenFunc(app.apiGetUser))
+	mux.Get("/api/openapi.json", http.HandlerFunc(app.apiSpec))
+
+	// Anything else under /api/ is not found, in JSON as well.
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.apiError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+	})
+	mux.Get("/api/", notFound)
+	mux.Post("/api/", notFound)
+	mux.Put("/api/", notFound)
+	mux.Del("/api/", notFound)
+}
+
+// Returns a page of public snippets, filtered by the query parameters of
+// the /snippets page.
+func (app *Application) apiListSnippets(w http.ResponseWriter, r *http.Request) {
+	opts, err := listOptions(r.URL.Query(), time.Now())
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := app.Snippets.List(r.Context(), opts)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	// An empty page has an empty list rather than null.
+	res := &apiSnippetPage{Snippets: append([]*models.Snippet{}, page.Snippets...)}
+	if page.Next != nil {
+		res.Next = page.Next.String()
+	}
+	app.writeJSON(w, http.StatusOK, res)
+}
+
+// Burn-after-reading snippets count a view, unless the user may edit them.
+// There is no link preview to protect the views from here.
+func (app *Application) apiGetSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet := snippetFromContext(r)
+	if snippet.ViewsLeft > 0 && !snippet.EditableBy(app.authenticatedUser(r)) {
+		var err error
+		snippet, err = app.Snippets.View(r.Context(), snippet.ID)
+		if err == models.ErrNoRecord {
+			app.apiError(w, http.StatusGone, "The snippet was deleted after its last view")
+			return
+		} else if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+	}
+	app.writeJSON(w, http.StatusOK, snippet)
+}
+
+func (app *Application) apiCreateSnippet(w http.ResponseWriter, r *http.Request) {
+	form, ok := app.readSnippetRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.authenticatedUser(r)
+	id, err := app.Snippets.Insert(r.Context(), user.ID, form.Get("title"), form.Get("content"), snippetLanguage(form), snippetVisibility(form), form.Get("password"), snippetExpiry(form), snippetMaxViews(form))
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	if tags := forms.Tags(form.Get("tags")); len(tags) > 0 {
+		if err := app.Snippets.SetTags(r.Context(), id, tags); err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+	}
+	app.writeSnippet(w, r, http.StatusCreated, id)
+}
+
+// Replaces the snippet, like the edit page does.
+func (app *Application) apiUpdateSnippet(w http.ResponseWriter, r *http.Request) {
+	form, ok := app.readSnippetRequest(w, r)
+	if !ok {
+		return
+	}
+
+	snippet := snippetFromContext(r)
+	err := app.Snippets.Update(r.Context(), snippet.ID, app.authenticatedUser(r).ID, form.Get("title"), form.Get("content"), snippetLanguage(form), snippetVisibility(form), snippetExpiry(form))
+	if err == nil {
+		err = app.Snippets.SetTags(r.Context(), snippet.ID, forms.Tags(form.Get("tags")))
+	}
+	if err == models.ErrNoRecord {
+		app.apiError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return
+	} else if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.writeSnippet(w, r, http.StatusOK, snippet.ID)
+}
+
+// Moves the snippet to the trash, from where the web pages can restore it.
+func (app *Application) apiDeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	err := app.Snippets.Delete(r.Context(), snippetFromContext(r).ID)
+	if err == models.ErrNoRecord {
+		app.apiError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return
+	} else if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Returns the user the request is authenticated as.
+func (app *Application) apiGetUser(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, http.StatusOK, app.authenticatedUser(r))
+}
+
+// Decodes and validates the body of the create and update requests. It
+// answers the request itself and returns false when they fail.
+func (app *Application) readSnippetRequest(w http.ResponseWriter, r *http.Request) (*forms.Form, bool) {
+	req := &apiSnippetRequest{}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(req); err != nil {
+		app.apiError(w, http.StatusBadRequest, fmt.Sprintf("Malformed JSON body: %s", err))
+		return nil, false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		app.apiError(w, http.StatusBadRequest, "The body must hold a single JSON object")
+		return nil, false
+	}
+
+	form := req.form()
+	app.validateSnippetForm(form)
+	if !form.Valid() {
+		app.writeJSON(w, http.StatusUnprocessableEntity, &apiError{
+			Error:  "The snippet is invalid",
+			Fields: form.Errors,
+		})
+		return nil, false
+	}
+	return form, true
+}
+
+// Answers with the snippet as it was saved.
+func (app *Application) writeSnippet(w http.ResponseWriter, r *http.Request, status, id int) {
+	snippet, err := app.Snippets.Get(r.Context(), id)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+	app.writeJSON(w, status, snippet)
+}
+
+// The JSON counterpart of loadSnippet. Password protected snippets are
+// refused to the users who can't edit them, there is no way to unlock them
+// through the API.
+func (app *Application) loadAPISnippet(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+		if err != nil || id < 1 {
+			app.apiError(w, http.StatusBadRequest, "The snippet ID must be a positive integer")
+			return
+		}
+		snippet, err := app.Snippets.Get(r.Context(), id)
+		if err == models.ErrNoRecord {
+			burned, err := app.Snippets.Burned(r.Context(), id, "")
+			if err != nil {
+				app.apiServerError(w, err)
+			} else if burned {
+				app.apiError(w, http.StatusGone, "The snippet was deleted after its last view")
+			} else {
+				app.apiError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+			}
+			return
+		} else if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+
+		user := app.authenticatedUser(r)
+		if !snippet.VisibleTo(user) {
+			app.apiError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+			return
+		}
+		if snippet.Protected() && !snippet.EditableBy(user) {
+			app.apiError(w, http.StatusForbidden, "The snippet is password protected")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeySnippet, snippet)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Authenticates the requests sent with an "Authorization: Bearer" header
+// from their API token, and puts the user in the request context just like
+// authenticate does with the session. Tokens with the read scope may only
+// send GET requests. The requests without the header go through as they
+// are.
+func (app *Application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 2 || !strings.EqualFold(fields[0], "Bearer") {
+			app.unauthorized(w, "The Authorization header must be \"Bearer <token>\"")
+			return
+		}
+
+		t, err := app.Tokens.Authenticate(r.Context(), fields[1])
+		if err == models.ErrInvalidCredentials {
+			app.unauthorized(w, "Invalid or revoked API token")
+			return
+		} else if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		if t.Scope != models.ScopeWrite && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			app.apiError(w, http.StatusForbidden, "The API token is read-only")
+			return
+		}
+		user, err := app.Users.Get(t.UserID)
+		if err == models.ErrNoRecord {
+			app.unauthorized(w, "Invalid or revoked API token")
+			return
+		} else if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), contextKeyUser, user)
+		ctx = context.WithValue(ctx, contextKeyToken, t)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Refuses the requests that weren't authenticated by authenticateToken.
+// Browsers send the session cookie along with cross-site requests too, and
+// the API has no CSRF token to tell them apart.
+func (app *Application) requireAPIToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(contextKeyToken).(*models.Token); !ok {
+			app.unauthorized(w, "An API token is required to change snippets")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The JSON counterpart of requireAuthenticatedUser.
+func (app *Application) requireAPIUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.authenticatedUser(r) == nil {
+			app.unauthorized(w, "Authentication required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The JSON counterpart of requireSnippetOwner.
+func (app *Application) requireAPISnippetOwner(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !snippetFromContext(r).EditableBy(app.authenticatedUser(r)) {
+			app.apiError(w, http.StatusForbidden, "Only the author of the snippet may change it")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Refuses the requests with a body that isn't JSON.
+func (app *Application) requireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				app.apiError(w, http.StatusUnsupportedMediaType, "The Content-Type must be application/json")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *Application) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		app.ErrorLog.Printf("Error: %s", err)
+	}
+}
+
+// Answers with {"error": message}.
+func (app *Application) apiError(w http.ResponseWriter, status int, message string) {
+	app.writeJSON(w, status, &apiError{Error: message})
+}
+
+// Answers 401 with the challenge for the API tokens.
+func (app *Application) unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="snippetbox"`)
+	app.apiError(w, http.StatusUnauthorized, message)
+}
+
+// The JSON counterpart of serverError.
+func (app *Application) apiServerError(w http.ResponseWriter, err error) {
+	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+	app.ErrorLog.Output(2, trace)
+	app.apiError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
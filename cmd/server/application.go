@@ -1,7 +1,10 @@
 package server
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"github.com/bmizerany/pat"
 	"github.com/golangcollege/sessions"
@@ -9,13 +12,18 @@ import (
 	"github.com/justinas/nosurf"
 	"html/template"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"snippetbox/pkg/diff"
 	"snippetbox/pkg/forms"
+	"snippetbox/pkg/highlight"
 	"snippetbox/pkg/models"
-	"snippetbox/pkg/models/mysql"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
 )
 
 var StaticFolder = "./ui/static"
@@ -24,13 +32,42 @@ type Application struct {
 	Port          *string
 	InfoLog       *log.Logger
 	ErrorLog      *log.Logger
-	Snippets      *mysql.SnippetDatabase
+	Snippets      models.SnippetStore
 	TemplateCache map[string]*template.Template
 	Session       *sessions.Session
 	TLSConfig     *tls.Config
-	Users         *mysql.UserModel
+	Users         models.UserStore
+	// The personal API tokens, see authenticateToken.
+	Tokens models.TokenStore
+	// Limits the wrong passwords entered per snippet. CreateServer sets a
+	// default when it is nil.
+	UnlockThrottle *Throttle
+	// The retention policy. Snippets may only be kept forever when
+	// NeverExpire is set, and for at most MaxExpiry when it isn't 0.
+	NeverExpire bool
+	MaxExpiry   time.Duration
+	// How long deleted snippets can be restored from the trash, see Reaper.
+	// CreateServer sets DefaultTrashRetention when it is 0.
+	TrashRetention time.Duration
+	// Enables the anonymous pastes of POST /paste, see paste.go. The paste
+	// URLs start with BaseURL, or with the host of the request when it is
+	// empty. CreateServer sets DefaultMaxPasteSize and a PasteLimiter of
+	// DefaultPasteRate per minute when they are unset.
+	Paste        bool
+	BaseURL      string
+	MaxPasteSize int64
+	PasteLimiter *RateLimiter
 }
 
+// The wrong passwords allowed per snippet within unlockWindow.
+const (
+	maxUnlockFailures = 5
+	unlockWindow      = 15 * time.Minute
+)
+
+// The snippets permanently deleted per statement when emptying the trash.
+const purgeBatchSize = 500
+
 var homePageTemplateFiles = []string{
 	"./ui/html/home.page.tmpl",
 	"./ui/html/base.layout.tmpl",
@@ -44,6 +81,18 @@ var showSnippetTemplateFiles = []string{
 }
 
 func CreateServer(app *Application) (*http.Server, error) {
+	if app.UnlockThrottle == nil {
+		app.UnlockThrottle = NewThrottle(maxUnlockFailures, unlockWindow)
+	}
+	if app.TrashRetention == 0 {
+		app.TrashRetention = DefaultTrashRetention
+	}
+	if app.MaxPasteSize == 0 {
+		app.MaxPasteSize = DefaultMaxPasteSize
+	}
+	if app.PasteLimiter == nil {
+		app.PasteLimiter = NewRateLimiter(DefaultPasteRate, pasteRateWindow)
+	}
 	routes := app.createRoutes()
 	srv := &http.Server{
 		Addr:         *app.Port,
@@ -60,19 +109,60 @@ func CreateServer(app *Application) (*http.Server, error) {
 func (app *Application) createRoutes() http.Handler {
 	standardMiddleware := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
 	dynamicMiddleware := alice.New(app.Session.Enable, noSurf, app.authenticate)
+	// For the pages that change a snippet, see requireSnippetOwner.
+	ownerMiddleware := dynamicMiddleware.Append(app.requireAuthenticatedUser, app.loadSnippet, app.requireSnippetOwner)
+	readMiddleware := dynamicMiddleware.Append(app.loadSnippet, app.requireUnlocked, app.confirmView)
+	forkMiddleware := dynamicMiddleware.Append(app.requireAuthenticatedUser, app.loadSnippet, app.requireUnlocked)
 
 	mux := pat.New()
 	mux.Get("/", dynamicMiddleware.ThenFunc(app.home))
+	mux.Get("/snippets", dynamicMiddleware.ThenFunc(app.listSnippets))
+	mux.Get("/search", dynamicMiddleware.ThenFunc(app.searchSnippets))
+	mux.Get("/tag/:name", dynamicMiddleware.ThenFunc(app.tagSnippets))
+	mux.Get("/tags/suggest", dynamicMiddleware.ThenFunc(app.suggestTags))
 	mux.Get("/snippet/create", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.createSnippetForm))
 	mux.Post("/snippet/create", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.createSnippet))
-	mux.Get("/snippet/:id", dynamicMiddleware.ThenFunc(app.showSnippet))
+	mux.Get("/snippet/:id", readMiddleware.ThenFunc(app.showSnippet))
+	mux.Get("/snippet/:id/edit", ownerMiddleware.ThenFunc(app.editSnippetForm))
+	mux.Post("/snippet/:id/edit", ownerMiddleware.ThenFunc(app.editSnippet))
+	mux.Post("/snippet/:id/delete", ownerMiddleware.ThenFunc(app.deleteSnippet))
+	mux.Get("/snippet/:id/raw", readMiddleware.ThenFunc(app.rawSnippet))
+	mux.Get("/snippet/:id/download", readMiddleware.ThenFunc(app.downloadSnippet))
+	mux.Get("/snippet/:id/history", readMiddleware.ThenFunc(app.snippetHistory))
+	mux.Get("/snippet/:id/diff", readMiddleware.ThenFunc(app.snippetDiff))
+	mux.Post("/snippet/:id/revisions/:revision/restore", ownerMiddleware.ThenFunc(app.restoreRevision))
+	mux.Post("/snippet/:id/unlock", dynamicMiddleware.Append(app.loadSnippet).ThenFunc(app.unlockSnippet))
+	mux.Post("/snippet/:id/view", dynamicMiddleware.Append(app.loadSnippet, app.requireUnlocked).ThenFunc(app.viewSnippet))
+	mux.Post("/snippet/:id/fork", forkMiddleware.ThenFunc(app.forkSnippet))
+	// Unlisted snippets are read through their slug, see loadSnippet.
+	mux.Get("/s/:slug", readMiddleware.ThenFunc(app.showSnippet))
+	mux.Get("/s/:slug/raw", readMiddleware.ThenFunc(app.rawSnippet))
+	mux.Get("/s/:slug/download", readMiddleware.ThenFunc(app.downloadSnippet))
+	mux.Get("/s/:slug/history", readMiddleware.ThenFunc(app.snippetHistory))
+	mux.Get("/s/:slug/diff", readMiddleware.ThenFunc(app.snippetDiff))
+	mux.Post("/s/:slug/unlock", dynamicMiddleware.Append(app.loadSnippet).ThenFunc(app.unlockSnippet))
+	mux.Post("/s/:slug/view", dynamicMiddleware.Append(app.loadSnippet, app.requireUnlocked).ThenFunc(app.viewSnippet))
+	mux.Post("/s/:slug/fork", forkMiddleware.ThenFunc(app.forkSnippet))
 
+	mux.Get("/user/snippets", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.userSnippets))
+	mux.Get("/trash", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.trash))
+	mux.Post("/trash/purge", dynamicMiddleware.Append(app.requireAuthenticatedUser, app.requireAdmin).ThenFunc(app.emptyTrash))
+	mux.Post("/trash/:id/restore", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.undeleteSnippet))
+	mux.Post("/trash/:id/purge", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.purgeSnippet))
+	mux.Get("/user/settings", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.userSettings))
+	mux.Post("/user/tokens", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.createToken))
+	mux.Post("/user/tokens/:id/revoke", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.revokeToken))
 	mux.Get("/user/signup", dynamicMiddleware.ThenFunc(app.signupUserForm))
 	mux.Post("/user/signup", dynamicMiddleware.ThenFunc(app.signupUser))
 	mux.Get("/user/login", dynamicMiddleware.ThenFunc(app.loginUserForm))
 	mux.Post("/user/login", dynamicMiddleware.ThenFunc(app.loginUser))
 	mux.Post("/user/logout", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.logoutUser))
 
+	app.createAPIRoutes(mux)
+	if app.Paste {
+		mux.Post("/paste", http.HandlerFunc(app.paste))
+	}
+
 	fileServer := http.FileServer(http.Dir(StaticFolder))
 	mux.Get("/static/", http.StripPrefix("/static", fileServer))
 
@@ -87,70 +177,702 @@ func (app *Application) createSnippetForm(w http.ResponseWriter, r *http.Request
 
 func (app *Application) home(w http.ResponseWriter, r *http.Request) {
 	app.InfoLog.Printf("home() called")
-	s, err := app.Snippets.Latest()
+	page, err := app.Snippets.List(r.Context(), models.ListOptions{})
 	if err != nil {
 		app.ErrorLog.Printf("Error: %s", err)
 		app.serverError(w, err)
 		return
 	}
 
+	data := &templateData{Snippets: page.Snippets}
+	if page.Next != nil {
+		data.NextPage = "/snippets?after=" + page.Next.String()
+	}
 	// Use the new render helper.
-	app.render(w, r, "home.page.tmpl", &templateData{
-		Snippet:  nil,
-		Snippets: s,
-	})
+	app.render(w, r, "home.page.tmpl", data)
 }
 
-func (app *Application) showSnippet(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
-	if err != nil || id < 1 {
-		app.ErrorLog.Printf("Error: %s", err)
+// Pages through every unexpired snippet. The filters are kept in the query
+// string so that every page can be bookmarked.
+func (app *Application) listSnippets(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts, err := listOptions(query, time.Now())
+	if err != nil {
 		app.badRequest(w, r)
 		return
 	}
 
-	snippet, err := app.Snippets.Get(id)
-	switch {
-	case err == models.ErrNoRecord:
-		app.ErrorLog.Printf("Error: %s", err)
-		app.notFound(w, r)
+	page, err := app.Snippets.List(r.Context(), opts)
+	if err != nil {
+		app.serverError(w, err)
 		return
-	case err != nil:
-		app.ErrorLog.Printf("Error: %s", err)
+	}
+
+	data := &templateData{Form: forms.New(query), Snippets: page.Snippets}
+	if opts.UserID != 0 {
+		data.Author, err = app.Users.Get(opts.UserID)
+		if err != nil && err != models.ErrNoRecord {
+			app.serverError(w, err)
+			return
+		}
+	}
+	setPageLinks(data, "/snippets", query, page, opts)
+	app.render(w, r, "snippets.page.tmpl", data)
+}
+
+// Searches the titles and contents of the unexpired snippets. The results
+// are paged and filtered just like on the /snippets page.
+func (app *Application) searchSnippets(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	data := &templateData{Form: forms.New(query)}
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		app.render(w, r, "search.page.tmpl", data)
+		return
+	}
+
+	opts, err := listOptions(query, time.Now())
+	if err != nil {
+		app.badRequest(w, r)
+		return
+	}
+	page, err := app.Snippets.Search(r.Context(), q, opts)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data.Snippets = page.Snippets
+	data.Terms = models.SearchTerms(q)
+	setPageLinks(data, "/search", query, page, opts)
+	app.render(w, r, "search.page.tmpl", data)
+}
+
+// Lists the unexpired snippets with the tag given in the URL, with the
+// same paging as the /snippets page.
+func (app *Application) tagSnippets(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	tag := query.Get(":name")
+	// pat adds the URL parameters to the query, keep them out of the links.
+	query.Del(":name")
+	opts, err := listOptions(query, time.Now())
+	if err != nil {
+		app.badRequest(w, r)
+		return
+	}
+	opts.Tag = tag
+
+	page, err := app.Snippets.List(r.Context(), opts)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := &templateData{Snippets: page.Snippets, Tag: tag}
+	setPageLinks(data, "/tag/"+url.PathEscape(tag), query, page, opts)
+	app.render(w, r, "tag.page.tmpl", data)
+}
+
+// Answers the tag autocomplete of the snippet forms with a JSON array of
+// the tags starting with the prefix query parameter.
+func (app *Application) suggestTags(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("prefix")))
+	tags, err := app.Snippets.SuggestTags(r.Context(), prefix, models.MaxTagSuggestions)
+	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tags); err != nil {
+		app.ErrorLog.Printf("Error: %s", err)
+	}
+}
+
+// Links the next and the first page of a listing, keeping the filters
+// found in query.
+func setPageLinks(data *templateData, path string, query url.Values, page *models.SnippetPage, opts models.ListOptions) {
+	if page.Next != nil {
+		query.Set("after", page.Next.String())
+		data.NextPage = path + "?" + query.Encode()
+	}
+	if opts.After != nil {
+		query.Del("after")
+		data.FirstPage = path + "?" + query.Encode()
+	}
+}
+
+// Reads the filters of the /snippets page. Dates are inclusive and
+// "expiring soon" means within the next day.
+func listOptions(query url.Values, now time.Time) (models.ListOptions, error) {
+	opts := models.ListOptions{}
+	var err error
+	if v := query.Get("author"); v != "" {
+		if opts.UserID, err = strconv.Atoi(v); err != nil || opts.UserID < 1 {
+			return opts, fmt.Errorf("invalid author %q", v)
+		}
+	}
+	if v := query.Get("from"); v != "" {
+		if opts.CreatedAfter, err = time.Parse("2006-01-02", v); err != nil {
+			return opts, err
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return opts, err
+		}
+		opts.CreatedBefore = to.AddDate(0, 0, 1)
+	}
+	if query.Get("expiring") == "soon" {
+		opts.ExpiresBefore = now.Add(24 * time.Hour)
+	}
+	if v := query.Get("limit"); v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil {
+			return opts, err
+		}
+	}
+	if v := query.Get("after"); v != "" {
+		if opts.After, err = models.ParseCursor(v); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// The snippet is loaded by readMiddleware, which hides the snippets the user
+// may not see or hasn't unlocked yet, and asks before burning any.
+func (app *Application) showSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet := snippetFromContext(r)
+	forks, err := app.Snippets.Forks(r.Context(), snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 	app.render(w, r, "show.page.tmpl", &templateData{
 		Snippet: snippet,
+		Forks:   forks,
 	})
 }
 
-func (app *Application) createSnippet(w http.ResponseWriter, r *http.Request) {
+// Copies the snippet into a new one owned by the user and opens it for
+// editing. Burn-after-reading snippets can't be forked, the fork would
+// outlive their last view.
+func (app *Application) forkSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet := snippetFromContext(r)
+	if snippet.ViewsLeft > 0 {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	id, err := app.Snippets.Fork(r.Context(), snippet.ID, app.authenticatedUser(r).ID)
+	if err == models.ErrNoRecord {
+		app.notFound(w, r)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.Session.Put(r, "flash", fmt.Sprintf("Snippet forked from #%d!", snippet.ID))
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d/edit", id), http.StatusSeeOther)
+}
+
+// Serves the content of the snippet as plain text, for curl and friends.
+// Shows a burn-after-reading snippet and counts the view, see confirmView.
+// The page is rendered rather than redirected to, as the last view deletes
+// the snippet.
+func (app *Application) viewSnippet(w http.ResponseWriter, r *http.Request) {
+	loaded := snippetFromContext(r)
+	snippet, err := app.Snippets.View(r.Context(), loaded.ID)
+	if err == models.ErrNoRecord {
+		app.snippetNotFound(w, r, loaded.ID, loaded.Slug)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	app.render(w, r, "show.page.tmpl", &templateData{Snippet: snippet})
+}
+
+// Answers 410 Gone for the snippets deleted after their last view and 404
+// for the others.
+func (app *Application) snippetNotFound(w http.ResponseWriter, r *http.Request, id int, slug string) {
+	burned, err := app.Snippets.Burned(r.Context(), id, slug)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !burned {
+		app.notFound(w, r)
+		return
+	}
+	app.renderStatus(w, r, http.StatusGone, "burned.page.tmpl", &templateData{})
+}
+
+// Checks the password of a protected snippet. Getting it right unlocks the
+// snippet for the rest of the session, see requireUnlocked.
+func (app *Application) unlockSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet := snippetFromContext(r)
+	if !app.UnlockThrottle.Allow(snippet.ID) {
+		app.clientError(w, http.StatusTooManyRequests)
+		return
+	}
 	if err := r.ParseForm(); err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
 	form := forms.New(r.PostForm)
-	form.Required("title", "content", "expires")
-	form.MaxLength("title", 100)
-	form.PermittedValues("expires", "365", "7", "1")
+	if !snippet.PasswordMatches(form.Get("password")) {
+		app.UnlockThrottle.Fail(snippet.ID)
+		form.Errors.Add("generic", "The password is incorrect")
+		app.renderStatus(w, r, http.StatusForbidden, "unlock.page.tmpl", &templateData{Snippet: snippet, Form: form})
+		return
+	}
+	app.Session.Put(r, unlockedKey(snippet.ID), true)
+	http.Redirect(w, r, snippet.Path(), http.StatusSeeOther)
+}
+
+// The session key telling that the snippet was unlocked.
+func unlockedKey(snippetID int) string {
+	return fmt.Sprintf("unlocked.%d", snippetID)
+}
+
+func (app *Application) rawSnippet(w http.ResponseWriter, r *http.Request) {
+	app.serveContent(w, r, snippetFromContext(r))
+}
+
+// Same as rawSnippet, saved by the browser under a name made from the
+// title and the language.
+func (app *Application) downloadSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet := snippetFromContext(r)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": snippetFilename(snippet),
+	}))
+	app.serveContent(w, r, snippet)
+}
 
+// How long clients may reuse a raw snippet before checking for changes.
+const rawMaxAge = 5 * time.Minute
+
+// Writes the content of the snippet. The ETag lets clients revalidate
+// cheaply, and nothing is cached past the expiry of the snippet.
+func (app *Application) serveContent(w http.ResponseWriter, r *http.Request, snippet *models.Snippet) {
+	maxAge := time.Until(snippet.Expires)
+	if maxAge > rawMaxAge {
+		maxAge = rawMaxAge
+	}
+	if maxAge < 0 {
+		maxAge = 0
+	}
+
+	sum := sha256.Sum256([]byte(snippet.Content))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// ServeContent takes care of Content-Length, ranges and If-None-Match.
+	http.ServeContent(w, r, "", time.Time{}, strings.NewReader(snippet.Content))
+}
+
+// Turns the title into a file name like "my-first-snippet.go". Titles
+// without letters or digits fall back on the ID.
+func snippetFilename(s *models.Snippet) string {
+	words := strings.FieldsFunc(strings.ToLower(s.Title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	name := strings.Join(words, "-")
+	if name == "" {
+		name = fmt.Sprintf("snippet-%d", s.ID)
+	}
+	return name + highlight.Lookup(s.Language).Extension
+}
+
+// Lists the snippets of the logged in user, including the expired ones.
+func (app *Application) userSnippets(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+	s, err := app.Snippets.ByUser(r.Context(), user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "user_snippets.page.tmpl", &templateData{
+		Snippets: s,
+	})
+}
+
+func (app *Application) createSnippet(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	app.validateSnippetForm(form)
 	if !form.Valid() {
 		app.render(w, r, "create.page.tmpl", &templateData{Form: form})
 		return
 	}
 
-	id, err := app.Snippets.Insert(form.Get("title"), form.Get("content"), form.Get("expires"))
+	user := app.authenticatedUser(r)
+	id, err := app.Snippets.Insert(r.Context(), user.ID, form.Get("title"), form.Get("content"), snippetLanguage(form), snippetVisibility(form), form.Get("password"), snippetExpiry(form), snippetMaxViews(form))
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
+	if tags := forms.Tags(form.Get("tags")); len(tags) > 0 {
+		if err := app.Snippets.SetTags(r.Context(), id, tags); err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
 	app.Session.Put(r, "flash", "Snippet successfully created!")
 	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", id), http.StatusSeeOther)
 }
 
+// The rules shared by the create and edit forms.
+func (app *Application) validateSnippetForm(form *forms.Form) {
+	form.Required("title", "content", "expires")
+	form.MaxLength("title", 100)
+	app.validateExpiry(form)
+	form.PermittedValues("language", highlight.Names()...)
+	form.PermittedValues("visibility", models.Visibilities...)
+	form.PermittedValues("views", "1", "2", "5", "10")
+	// bcrypt ignores everything past 72 bytes.
+	form.MaxLength("password", 72)
+	form.ValidTags("tags", models.MaxTags, models.MaxTagLength)
+}
+
+// The shortest time a snippet may be kept.
+const minExpiry = 5 * time.Minute
+
+// The expires field holds a number of days, "custom" for the duration in
+// expires_in, "at" for the UTC datetime in expires_at, or "never".
+func (app *Application) validateExpiry(form *forms.Form) {
+	now := time.Now().UTC()
+	var latest time.Time
+	if app.MaxExpiry > 0 {
+		latest = now.Add(app.MaxExpiry)
+	}
+
+	switch form.Get("expires") {
+	case "never":
+		if !app.NeverExpire {
+			form.Errors.Add("expires", "Snippets must expire")
+		}
+	case "custom":
+		form.Required("expires_in")
+		form.DurationBetween("expires_in", minExpiry, app.MaxExpiry)
+	case "at":
+		form.Required("expires_at")
+		// From Never on, the snippet would be kept forever.
+		if !app.NeverExpire && (latest.IsZero() || !latest.Before(models.Never)) {
+			latest = models.Never.Add(-time.Minute)
+		}
+		form.DatetimeBetween("expires_at", now.Add(minExpiry), latest)
+	default:
+		form.PermittedValues("expires", "365", "7", "1")
+		if days, err := strconv.Atoi(form.Get("expires")); err == nil && !latest.IsZero() && now.AddDate(0, 0, days).After(latest) {
+			form.Errors.Add("expires", fmt.Sprintf("Snippets are kept for at most %s", forms.FormatDuration(app.MaxExpiry)))
+		}
+	}
+}
+
+// Returns the expiry time picked in the form, see validateExpiry.
+func snippetExpiry(form *forms.Form) time.Time {
+	now := time.Now().UTC()
+	switch form.Get("expires") {
+	case "never":
+		return models.Never
+	case "custom":
+		d, _ := forms.ParseDuration(form.Get("expires_in"))
+		return now.Add(d)
+	case "at":
+		t, _ := time.ParseInLocation(forms.DatetimeLayout, form.Get("expires_at"), time.UTC)
+		return t
+	default:
+		days, _ := strconv.Atoi(form.Get("expires"))
+		return now.AddDate(0, 0, days)
+	}
+}
+
+// Returns the language picked in the form, or the one detected from the
+// content when it was left blank.
+func snippetLanguage(form *forms.Form) string {
+	if language := form.Get("language"); language != "" {
+		return language
+	}
+	return highlight.Detect(form.Get("content"))
+}
+
+// Snippets can be read any number of times unless the form says otherwise.
+func snippetMaxViews(form *forms.Form) int {
+	views, _ := strconv.Atoi(form.Get("views"))
+	return views
+}
+
+// Snippets are public unless the form says otherwise.
+func snippetVisibility(form *forms.Form) string {
+	if visibility := form.Get("visibility"); visibility != "" {
+		return visibility
+	}
+	return models.Public
+}
+
+// Returns the snippet loaded by loadSnippet.
+func snippetFromContext(r *http.Request) *models.Snippet {
+	snippet, _ := r.Context().Value(contextKeySnippet).(*models.Snippet)
+	return snippet
+}
+
+func (app *Application) editSnippetForm(w http.ResponseWriter, r *http.Request) {
+	snippet := snippetFromContext(r)
+	app.render(w, r, "edit.page.tmpl", &templateData{
+		Snippet: snippet,
+		Form: forms.New(url.Values{
+			"title":      {snippet.Title},
+			"content":    {snippet.Content},
+			"language":   {snippet.Language},
+			"visibility": {snippet.Visibility},
+			"tags":       {strings.Join(snippet.Tags, ", ")},
+		}),
+	})
+}
+
+func (app *Application) editSnippet(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippet := snippetFromContext(r)
+	form := forms.New(r.PostForm)
+	app.validateSnippetForm(form)
+	if !form.Valid() {
+		app.render(w, r, "edit.page.tmpl", &templateData{Snippet: snippet, Form: form})
+		return
+	}
+
+	err := app.Snippets.Update(r.Context(), snippet.ID, app.authenticatedUser(r).ID, form.Get("title"), form.Get("content"), snippetLanguage(form), snippetVisibility(form), snippetExpiry(form))
+	if err == nil {
+		err = app.Snippets.SetTags(r.Context(), snippet.ID, forms.Tags(form.Get("tags")))
+	}
+	if err == models.ErrNoRecord {
+		app.notFound(w, r)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.Session.Put(r, "flash", "Snippet successfully updated!")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", snippet.ID), http.StatusSeeOther)
+}
+
+func (app *Application) deleteSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet := snippetFromContext(r)
+	err := app.Snippets.Delete(r.Context(), snippet.ID)
+	if err == models.ErrNoRecord {
+		app.notFound(w, r)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.Session.Put(r, "flash", "Snippet moved to the trash.")
+	http.Redirect(w, r, "/user/snippets", http.StatusSeeOther)
+}
+
+// Lists the deleted snippets of the user, or of every user for admins.
+func (app *Application) trash(w http.ResponseWriter, r *http.Request) {
+	s, err := app.Snippets.Trash(r.Context(), app.trashOwner(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "trash.page.tmpl", &templateData{
+		Snippets: s,
+	})
+}
+
+func (app *Application) undeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.trashedSnippet(r)
+	if err == nil {
+		err = app.Snippets.Undelete(r.Context(), snippet.ID)
+	}
+	if err == models.ErrNoRecord {
+		app.notFound(w, r)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.Session.Put(r, "flash", "Snippet successfully restored!")
+	http.Redirect(w, r, "/user/snippets", http.StatusSeeOther)
+}
+
+func (app *Application) purgeSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.trashedSnippet(r)
+	if err == nil {
+		err = app.Snippets.Purge(r.Context(), snippet.ID)
+	}
+	if err == models.ErrNoRecord {
+		app.notFound(w, r)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.Session.Put(r, "flash", "Snippet permanently deleted!")
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
+
+// Permanently deletes every snippet in the trash, whoever deleted it.
+func (app *Application) emptyTrash(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	total := 0
+	for {
+		n, err := app.Snippets.PurgeDeleted(r.Context(), now, purgeBatchSize)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		total += n
+		if n < purgeBatchSize {
+			break
+		}
+	}
+	app.Session.Put(r, "flash", fmt.Sprintf("%d snippets permanently deleted!", total))
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
+
+// The user whose trash is shown, 0 for the admins who see every trash.
+func (app *Application) trashOwner(r *http.Request) int {
+	user := app.authenticatedUser(r)
+	if user.Admin {
+		return 0
+	}
+	return user.ID
+}
+
+// Returns the snippet with the :id of the URL from the trash of the user.
+// The snippets in the trash of someone else are not found.
+func (app *Application) trashedSnippet(r *http.Request) (*models.Snippet, error) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		return nil, models.ErrNoRecord
+	}
+	trash, err := app.Snippets.Trash(r.Context(), app.trashOwner(r))
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range trash {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+// Lists every saved version of the snippet.
+func (app *Application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	snippet := snippetFromContext(r)
+	revisions, err := app.Snippets.Revisions(r.Context(), snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "history.page.tmpl", &templateData{
+		Snippet:   snippet,
+		Revisions: revisions,
+	})
+}
+
+// Shows the changes between the revisions given by the from and to query
+// parameters. Without from, the revision is compared to the one before it.
+func (app *Application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	snippet := snippetFromContext(r)
+	toID, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		app.badRequest(w, r)
+		return
+	}
+	to, err := app.Snippets.Revision(r.Context(), snippet.ID, toID)
+	if err == models.ErrNoRecord {
+		app.notFound(w, r)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	var from *models.Revision
+	if r.URL.Query().Get("from") != "" {
+		fromID, err := strconv.Atoi(r.URL.Query().Get("from"))
+		if err != nil {
+			app.badRequest(w, r)
+			return
+		}
+		from, err = app.Snippets.Revision(r.Context(), snippet.ID, fromID)
+		if err == models.ErrNoRecord {
+			app.notFound(w, r)
+			return
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	} else {
+		from, err = app.previousRevision(r, to)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.render(w, r, "diff.page.tmpl", &templateData{
+		Snippet: snippet,
+		From:    from,
+		To:      to,
+		Diff:    diff.Unified(from.Content, to.Content, 3),
+	})
+}
+
+// Returns the revision saved before the given one. The first revision is
+// compared to an empty one.
+func (app *Application) previousRevision(r *http.Request, revision *models.Revision) (*models.Revision, error) {
+	revisions, err := app.Snippets.Revisions(r.Context(), revision.SnippetID)
+	if err != nil {
+		return nil, err
+	}
+	// Newest first, so the first older one is the previous revision.
+	for _, previous := range revisions {
+		if previous.ID < revision.ID {
+			return previous, nil
+		}
+	}
+	return &models.Revision{SnippetID: revision.SnippetID}, nil
+}
+
+func (app *Application) restoreRevision(w http.ResponseWriter, r *http.Request) {
+	snippet := snippetFromContext(r)
+	revisionID, err := strconv.Atoi(r.URL.Query().Get(":revision"))
+	if err != nil {
+		app.badRequest(w, r)
+		return
+	}
+
+	err = app.Snippets.Restore(r.Context(), snippet.ID, revisionID, app.authenticatedUser(r).ID)
+	if err == models.ErrNoRecord {
+		app.notFound(w, r)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.Session.Put(r, "flash", "Revision successfully restored!")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", snippet.ID), http.StatusSeeOther)
+}
+
 func (app *Application) serverError(w http.ResponseWriter, err error) {
 	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
 	app.ErrorLog.Output(2, trace)
@@ -243,6 +965,70 @@ func (app *Application) logoutUser(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// Lists the API tokens of the user, with the form to create another one.
+func (app *Application) userSettings(w http.ResponseWriter, r *http.Request) {
+	app.renderSettings(w, r, forms.New(nil), "")
+}
+
+// Shows the new token right away, it can't be read again afterwards.
+func (app *Application) createToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("name", "scope")
+	form.MaxLength("name", 100)
+	form.PermittedValues("scope", models.Scopes...)
+	if !form.Valid() {
+		app.renderSettings(w, r, form, "")
+		return
+	}
+
+	token, err := app.Tokens.Insert(r.Context(), app.authenticatedUser(r).ID, form.Get("name"), form.Get("scope"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	app.renderSettings(w, r, forms.New(nil), token)
+}
+
+func (app *Application) revokeToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+	err = app.Tokens.Revoke(r.Context(), id, app.authenticatedUser(r).ID)
+	if err == models.ErrNoRecord {
+		app.notFound(w, r)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.Session.Put(r, "flash", "Token successfully revoked!")
+	http.Redirect(w, r, "/user/settings", http.StatusSeeOther)
+}
+
+// Renders the settings page with the token form and the new token, if one
+// was just created.
+func (app *Application) renderSettings(w http.ResponseWriter, r *http.Request, form *forms.Form, newToken string) {
+	tokens, err := app.Tokens.ByUser(r.Context(), app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "settings.page.tmpl", &templateData{
+		Form:     form,
+		Tokens:   tokens,
+		NewToken: newToken,
+	})
+}
+
 // Returns the user ID when the user is authenticated.
 // Returns 0 otherwise
 func (app *Application) authenticatedUser(r *http.Request) *models.User {
@@ -263,5 +1049,10 @@ func (app *Application) addDefaultData(td *templateData, r *http.Request) *templ
 	td.CurrentYear = time.Now().Year()
 	td.Flash = app.Session.PopString(r, "flash")
 	td.AuthenticatedUser = app.authenticatedUser(r)
+	td.NeverExpire = app.NeverExpire
+	if app.MaxExpiry > 0 {
+		td.MaxExpiry = forms.FormatDuration(app.MaxExpiry)
+	}
+	td.TrashRetention = forms.FormatDuration(app.TrashRetention)
 	return td
 }
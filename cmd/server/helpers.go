@@ -15,6 +15,11 @@ func CreateLoggers() (*log.Logger, *log.Logger) {
 }
 
 func (app *Application) render(w http.ResponseWriter, r *http.Request, name string, td *templateData) {
+	app.renderStatus(w, r, http.StatusOK, name, td)
+}
+
+// Same as render, answering with another status code.
+func (app *Application) renderStatus(w http.ResponseWriter, r *http.Request, status int, name string, td *templateData) {
 	ts, ok := app.TemplateCache[name]
 	if !ok {
 		app.serverError(w, fmt.Errorf("The template %s does not exist", name))
@@ -29,5 +34,6 @@ func (app *Application) render(w http.ResponseWriter, r *http.Request, name stri
 		return
 	}
 
+	w.WriteHeader(status)
 	buf.WriteTo(w)
 }
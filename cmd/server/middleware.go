@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"github.com/justinas/nosurf"
 	"net/http"
+	"snippetbox/pkg/forms"
 	"snippetbox/pkg/models"
+	"strconv"
 )
 
 type contextKey string
 
 var contextKeyUser = contextKey("user")
+var contextKeySnippet = contextKey("snippet")
+var contextKeyToken = contextKey("token")
 
 func secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -55,6 +59,103 @@ func (app *Application) requireAuthenticatedUser(next http.Handler) http.Handler
 	})
 }
 
+// Loads the snippet from the :id or the :slug in the URL and puts it in the
+// request context, see snippetFromContext(). Snippets the user may not see
+// are not found, see Snippet.VisibleTo.
+func (app *Application) loadSnippet(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var snippet *models.Snippet
+		var err error
+		id := 0
+		slug := r.URL.Query().Get(":slug")
+		if slug != "" {
+			snippet, err = app.Snippets.BySlug(r.Context(), slug)
+		} else {
+			id, err = strconv.Atoi(r.URL.Query().Get(":id"))
+			if err != nil || id < 1 {
+				app.badRequest(w, r)
+				return
+			}
+			snippet, err = app.Snippets.Get(r.Context(), id)
+		}
+		if err == models.ErrNoRecord {
+			app.snippetNotFound(w, r, id, slug)
+			return
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		// The slug stands in for the ID of unlisted snippets, but private
+		// ones stay private.
+		bySlug := slug != "" && snippet.Visibility == models.Unlisted
+		if !bySlug && !snippet.VisibleTo(app.authenticatedUser(r)) {
+			app.notFound(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeySnippet, snippet)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Asks for the password of protected snippets before letting the request
+// through, see unlockSnippet. The users who may edit the snippet don't need
+// it. Must come after loadSnippet in the chain.
+func (app *Application) requireUnlocked(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snippet := snippetFromContext(r)
+		if snippet.Protected() && !snippet.EditableBy(app.authenticatedUser(r)) && !app.Session.GetBool(r, unlockedKey(snippet.ID)) {
+			app.renderStatus(w, r, http.StatusForbidden, "unlock.page.tmpl", &templateData{
+				Snippet: snippet,
+				Form:    forms.New(nil),
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Burn-after-reading snippets are only shown by viewSnippet, once the user
+// confirmed they want to read it. Link previews only follow GET requests,
+// so they can't use up the views. The users who may edit the snippet see
+// it right away. Must come after loadSnippet in the chain.
+func (app *Application) confirmView(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snippet := snippetFromContext(r)
+		if snippet.ViewsLeft > 0 && !snippet.EditableBy(app.authenticatedUser(r)) {
+			w.Header().Set("Cache-Control", "no-store")
+			app.render(w, r, "burn.page.tmpl", &templateData{Snippet: snippet})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Only lets the author of the snippet or an admin through. Must come after
+// requireAuthenticatedUser and loadSnippet in the chain.
+func (app *Application) requireSnippetOwner(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !snippetFromContext(r).EditableBy(app.authenticatedUser(r)) {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Only lets admins through. Must come after requireAuthenticatedUser in the
+// chain.
+func (app *Application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.authenticatedUser(r).Admin {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // Check if a userID value exists in the session. If this isn't
 // present then call the next handler in the chain as normal.
 func (app *Application) authenticate(next http.Handler) http.Handler {
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"snippetbox/pkg/models"
+	"strings"
+	"time"
+)
+
+// A JSON object of the OpenAPI document.
+type jsonObject map[string]interface{}
+
+// The types of the components of the OpenAPI document, by name. The schemas
+// refer to each other by these names, see jsonSchema.
+var apiSchemas = map[string]reflect.Type{
+	"Snippet":        reflect.TypeOf(models.Snippet{}),
+	"User":           reflect.TypeOf(models.User{}),
+	"SnippetPage":    reflect.TypeOf(apiSnippetPage{}),
+	"SnippetRequest": reflect.TypeOf(apiSnippetRequest{}),
+	"Error":          reflect.TypeOf(apiError{}),
+}
+
+// Serves the OpenAPI 3 document of the JSON API.
+func (app *Application) apiSpec(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, http.StatusOK, openAPISpec())
+}
+
+// Returns the OpenAPI 3 document describing the routes of createAPIRoutes.
+// The schemas are derived from the types the handlers encode and decode,
+// so that they can't drift apart.
+func openAPISpec() jsonObject {
+	schemas := jsonObject{}
+	for name, t := range apiSchemas {
+		schemas[name] = structSchema(t)
+	}
+
+	snippetID := jsonObject{
+		"name":        "id",
+		"in":          "path",
+		"required":    true,
+		"description": "The ID of the snippet.",
+		"schema":      jsonObject{"type": "integer", "minimum": 1},
+	}
+	// Reading works without authentication, changing needs a token with
+	// the write scope.
+	anyone := []jsonObject{{}, {"bearerToken": []string{}}, {"sessionCookie": []string{}}}
+	users := []jsonObject{{"bearerToken": []string{}}, {"sessionCookie": []string{}}}
+	writers := []jsonObject{{"bearerToken": []string{}}}
+
+	return jsonObject{
+		"openapi": "3.0.3",
+		"info": jsonObject{
+			"title":   "Snippetbox API",
+			"version": "1",
+		},
+		"paths": jsonObject{
+			"/api/v1/snippets": jsonObject{
+				"get": jsonObject{
+					"operationId": "listSnippets",
+					"summary":     "Lists a page of public snippets, newest first.",
+					"security":    anyone,
+					"parameters": []jsonObject{
+						queryParameter("author", "Only the snippets of this user ID.", jsonObject{"type": "integer", "minimum": 1}),
+						queryParameter("from", "Only the snippets created on or after this day.", jsonObject{"type": "string", "format": "date"}),
+						queryParameter("to", "Only the snippets created on or before this day.", jsonObject{"type": "string", "format": "date"}),
+						queryParameter("expiring", "Only the snippets expiring within a day.", jsonObject{"type": "string", "enum": []string{"soon"}}),
+						queryParameter("limit", "The size of the page.", jsonObject{"type": "integer", "minimum": 1, "maximum": models.MaxPageSize}),
+						queryParameter("after", "The next cursor of the previous page.", jsonObject{"type": "string"}),
+					},
+					"responses": responses(http.StatusOK, "SnippetPage", http.StatusBadRequest, http.StatusUnauthorized),
+				},
+				"post": jsonObject{
+					"operationId": "createSnippet",
+					"summary":     "Creates a snippet.",
+					"security":    writers,
+					"requestBody": requestBody("SnippetRequest"),
+					"responses":   responses(http.StatusCreated, "Snippet", http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity),
+				},
+			},
+			"/api/v1/snippets/{id}": jsonObject{
+				"parameters": []jsonObject{snippetID},
+				"get": jsonObject{
+					"operationId": "getSnippet",
+					"summary":     "Reads a snippet. Reading a burn-after-reading snippet uses up one of its views.",
+					"security":    anyone,
+					"responses":   responses(http.StatusOK, "Snippet", http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusGone),
+				},
+				"put": jsonObject{
+					"operationId": "updateSnippet",
+					"summary":     "Replaces a snippet. The password and the views are left as they are.",
+					"security":    writers,
+					"requestBody": requestBody("SnippetRequest"),
+					"responses":   responses(http.StatusOK, "Snippet", http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusGone, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity),
+				},
+				"delete": jsonObject{
+					"operationId": "deleteSnippet",
+					"summary":     "Moves a snippet to the trash.",
+					"security":    writers,
+					"responses":   responses(http.StatusNoContent, "", http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusGone),
+				},
+			},
+			"/api/v1/user": jsonObject{
+				"get": jsonObject{
+					"operationId": "getUser",
+					"summary":     "Returns the authenticated user.",
+					"security":    users,
+					"responses":   responses(http.StatusOK, "User", http.StatusUnauthorized),
+				},
+			},
+			"/api/openapi.json": jsonObject{
+				"get": jsonObject{
+					"operationId": "getSpec",
+					"summary":     "Returns this document.",
+					"security":    []jsonObject{{}},
+					"responses": jsonObject{
+						"200": jsonObject{
+							"description": "The OpenAPI document.",
+							"content":     jsonObject{"application/json": jsonObject{"schema": jsonObject{"type": "object"}}},
+						},
+					},
+				},
+			},
+		},
+		"components": jsonObject{
+			"schemas": schemas,
+			"securitySchemes": jsonObject{
+				"bearerToken": jsonObject{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "A personal API token, created on the /user/settings page.",
+				},
+				"sessionCookie": jsonObject{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": "session",
+				},
+			},
+		},
+	}
+}
+
+func queryParameter(name, description string, schema jsonObject) jsonObject {
+	return jsonObject{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      schema,
+	}
+}
+
+func requestBody(schema string) jsonObject {
+	return jsonObject{
+		"required": true,
+		"content":  jsonContent(schema),
+	}
+}
+
+// Returns the responses of an operation: the successful one with the given
+// schema, none when it is empty, and an Error for each error status.
+func responses(status int, schema string, errorStatuses ...int) jsonObject {
+	success := jsonObject{"description": http.StatusText(status)}
+	if schema != "" {
+		success["content"] = jsonContent(schema)
+	}
+	res := jsonObject{fmt.Sprint(status): success}
+	for _, s := range errorStatuses {
+		res[fmt.Sprint(s)] = jsonObject{
+			"description": http.StatusText(s),
+			"content":     jsonContent("Error"),
+		}
+	}
+	return res
+}
+
+func jsonContent(schema string) jsonObject {
+	return jsonObject{"application/json": jsonObject{"schema": schemaRef(schema)}}
+}
+
+func schemaRef(name string) jsonObject {
+	return jsonObject{"$ref": "#/components/schemas/" + name}
+}
+
+// Returns the schema of the struct type t as encoding/json writes it. The
+// fields tagged "-" are left out and the omitempty ones are optional.
+func structSchema(t reflect.Type) jsonObject {
+	properties := jsonObject{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if field.PkgPath != "" || tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, options = tag[:i], tag[i+1:]
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = jsonSchema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := jsonObject{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// Returns the schema of the values of type t. The structs of apiSchemas
+// are referred to by name.
+func jsonSchema(t reflect.Type) jsonObject {
+	if t == reflect.TypeOf(time.Time{}) {
+		return jsonObject{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return jsonSchema(t.Elem())
+	case reflect.Bool:
+		return jsonObject{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return jsonObject{"type": "integer"}
+	case reflect.String:
+		return jsonObject{"type": "string"}
+	case reflect.Slice:
+		return jsonObject{"type": "array", "items": jsonSchema(t.Elem())}
+	case reflect.Map:
+		return jsonObject{"type": "object", "additionalProperties": jsonSchema(t.Elem())}
+	case reflect.Struct:
+		for name, schema := range apiSchemas {
+			if schema == t {
+				return schemaRef(name)
+			}
+		}
+		return structSchema(t)
+	}
+	panic(fmt.Sprintf("openapi: no schema for %s", t))
+}
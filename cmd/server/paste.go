@@ -0,0 +1,250 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"snippetbox/pkg/highlight"
+	"snippetbox/pkg/models"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The defaults of the paste limits, see Application.
+const (
+	DefaultMaxPasteSize = 512 << 10
+	DefaultPasteRate    = 10
+	pasteRateWindow     = time.Minute
+	// What multipart/form-data adds around the file: the boundaries and the
+	// headers of the parts.
+	multipartOverhead = 4 << 10
+)
+
+// How long the TCP listener waits for the pastes: a client that sends
+// nothing for pasteIdleTimeout is done, like termbin does for the netcat
+// versions that never close their side. No paste takes more than
+// pasteTimeout.
+const (
+	pasteIdleTimeout = 2 * time.Second
+	pasteTimeout     = 30 * time.Second
+)
+
+// How long the pastes are kept, unless Application.MaxExpiry is shorter.
+const pasteExpiry = 7 * 24 * time.Hour
+
+// The errors answered to the clients.
+var (
+	errPasteEmpty  = errors.New("nothing to paste")
+	errPasteBinary = errors.New("only text can be pasted")
+	errPasteSize   = errors.New("the paste is too large")
+	errPasteSlow   = errors.New("the paste took too long")
+	errPasteRate   = errors.New("too many pastes, try again later")
+	errPasteField  = errors.New("the file must be sent in the f field")
+)
+
+// Creates an anonymous snippet from the body of the request, or from its f
+// field when it is sent as multipart/form-data (curl -F f=@file), and
+// answers with its URL in plain text. Only the routes of the pages need a
+// session and a CSRF token, so this one is left out of them.
+func (app *Application) paste(w http.ResponseWriter, r *http.Request) {
+	if !app.PasteLimiter.Allow(clientIP(r.RemoteAddr)) {
+		http.Error(w, errPasteRate.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	var name string
+	var content []byte
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		r.Body = http.MaxBytesReader(w, r.Body, app.MaxPasteSize+multipartOverhead)
+		name, content, err = readPasteFile(r, app.MaxPasteSize)
+	} else {
+		r.Body = http.MaxBytesReader(w, r.Body, app.MaxPasteSize)
+		content, err = io.ReadAll(r.Body)
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) || err == errPasteSize {
+		http.Error(w, errPasteSize.Error(), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	baseURL := app.BaseURL
+	if baseURL == "" {
+		baseURL = "https://" + r.Host
+	}
+	url, err := app.savePaste(r.Context(), baseURL, name, content)
+	if err == errPasteEmpty || err == errPasteBinary {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Location", url)
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, url)
+}
+
+// Returns the name and the content of the f file of a multipart form. The
+// file may hold at most max bytes.
+func readPasteFile(r *http.Request, max int64) (string, []byte, error) {
+	// The body is already limited, so the whole form may be kept in
+	// memory.
+	if err := r.ParseMultipartForm(max + multipartOverhead); err != nil {
+		return "", nil, err
+	}
+	defer r.MultipartForm.RemoveAll()
+	f, header, err := r.FormFile("f")
+	if err != nil {
+		return "", nil, errPasteField
+	}
+	defer f.Close()
+	content, err := io.ReadAll(io.LimitReader(f, max+1))
+	if err == nil && int64(len(content)) > max {
+		err = errPasteSize
+	}
+	return header.Filename, content, err
+}
+
+// Saves the paste as an unlisted snippet and returns its URL. The language
+// comes from the extension of the file name, if any, or from the content.
+func (app *Application) savePaste(ctx context.Context, baseURL, name string, content []byte) (string, error) {
+	if len(bytes.TrimSpace(content)) == 0 {
+		return "", errPasteEmpty
+	}
+	if !utf8.Valid(content) {
+		return "", errPasteBinary
+	}
+
+	title := name
+	if title == "" {
+		title = "Paste"
+	}
+	if utf8.RuneCountInString(title) > 100 {
+		title = string([]rune(title)[:100])
+	}
+	language := highlight.ForFile(name)
+	if language == "" {
+		language = highlight.Detect(string(content))
+	}
+	expiry := pasteExpiry
+	if app.MaxExpiry > 0 && app.MaxExpiry < expiry {
+		expiry = app.MaxExpiry
+	}
+
+	id, err := app.Snippets.Insert(ctx, 0, title, string(content), language, models.Unlisted, "", time.Now().UTC().Add(expiry), 0)
+	if err != nil {
+		return "", err
+	}
+	snippet, err := app.Snippets.Get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(baseURL, "/") + snippet.Path(), nil
+}
+
+// Accepts termbin-style pastes on l until ctx is done: every connection
+// sends a paste and reads back its URL, e.g. `cat file | nc host 9999`.
+// There is no request to take the host from, so the URLs start with
+// https://localhost and the port of the server when BaseURL is empty.
+func (app *Application) ServePastes(ctx context.Context, l net.Listener) error {
+	baseURL := app.BaseURL
+	if baseURL == "" {
+		baseURL = "https://localhost" + *app.Port
+	}
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		go app.pasteConn(ctx, conn, baseURL)
+	}
+}
+
+// Reads a paste from the connection and writes back its URL, or the error.
+func (app *Application) pasteConn(ctx context.Context, conn net.Conn, baseURL string) {
+	defer conn.Close()
+	if !app.PasteLimiter.Allow(clientIP(conn.RemoteAddr().String())) {
+		fmt.Fprintln(conn, errPasteRate)
+		return
+	}
+
+	content, err := readPasteConn(conn, app.MaxPasteSize)
+	if err == nil {
+		var url string
+		url, err = app.savePaste(ctx, baseURL, "", content)
+		if err == nil {
+			fmt.Fprintln(conn, url)
+			return
+		}
+	}
+	switch err {
+	case errPasteEmpty, errPasteBinary, errPasteSize, errPasteSlow:
+		fmt.Fprintln(conn, err)
+	default:
+		app.ErrorLog.Printf("Paste from %s: %s", conn.RemoteAddr(), err)
+		fmt.Fprintln(conn, http.StatusText(http.StatusInternalServerError))
+	}
+}
+
+// Reads until EOF or until the client goes idle. Fails when more than max
+// bytes were sent or when it takes longer than pasteTimeout.
+func readPasteConn(conn net.Conn, max int64) ([]byte, error) {
+	deadline := time.Now().Add(pasteTimeout)
+	var buf bytes.Buffer
+	chunk := make([]byte, 32<<10)
+	for {
+		idle := time.Now().Add(pasteIdleTimeout)
+		if idle.After(deadline) {
+			idle = deadline
+		}
+		conn.SetReadDeadline(idle)
+		n, err := conn.Read(chunk)
+		buf.Write(chunk[:n])
+		if int64(buf.Len()) > max {
+			return nil, errPasteSize
+		}
+		var netErr net.Error
+		timeout := errors.As(err, &netErr) && netErr.Timeout()
+		if timeout && !time.Now().Before(deadline) {
+			return nil, errPasteSlow
+		}
+		if err == io.EOF || timeout {
+			// The client may still be reading the answer.
+			conn.SetDeadline(time.Now().Add(pasteIdleTimeout))
+			return buf.Bytes(), nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Returns the IP address of a host:port remote address, which is what the
+// pastes are rate limited on.
+func clientIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"log"
+	"snippetbox/pkg/models"
+	"time"
+)
+
+// Reaper deletes the snippets that expired more than Grace ago, and the
+// ones in the trash for more than Retention, every Interval. The grace
+// period leaves the authors some time to see their expired snippets on
+// their page. Snippets are deleted BatchSize at a time so that no single
+// statement locks the table for long.
+type Reaper struct {
+	Snippets  models.SnippetStore
+	Interval  time.Duration
+	Grace     time.Duration
+	Retention time.Duration
+	BatchSize int
+	InfoLog   *log.Logger
+	ErrorLog  *log.Logger
+}
+
+// How long deleted snippets stay in the trash by default.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+func NewReaper(snippets models.SnippetStore, interval, grace time.Duration, infoLog, errorLog *log.Logger) *Reaper {
+	return &Reaper{
+		Snippets:  snippets,
+		Interval:  interval,
+		Grace:     grace,
+		Retention: DefaultTrashRetention,
+		BatchSize: 500,
+		InfoLog:   infoLog,
+		ErrorLog:  errorLog,
+	}
+}
+
+// Reaps right away and then every Interval, until ctx is done.
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Reap(ctx); err != nil && ctx.Err() == nil {
+			r.ErrorLog.Printf("Reaper: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			r.InfoLog.Printf("Reaper: stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deletes the snippets that expired more than Grace ago and the ones in
+// the trash for more than Retention, batch by batch, and returns how many
+// were deleted. It stops between two batches when ctx is done.
+func (r *Reaper) Reap(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	expired, err := r.deleteBatches(ctx, r.Snippets.DeleteExpired, now.Add(-r.Grace))
+	if expired > 0 {
+		r.InfoLog.Printf("Reaper: deleted %d snippets expired before %s", expired, now.Add(-r.Grace).Format(time.RFC3339))
+	}
+	if err != nil {
+		return expired, err
+	}
+	purged, err := r.deleteBatches(ctx, r.Snippets.PurgeDeleted, now.Add(-r.Retention))
+	if purged > 0 {
+		r.InfoLog.Printf("Reaper: purged %d snippets deleted before %s", purged, now.Add(-r.Retention).Format(time.RFC3339))
+	}
+	return expired + purged, err
+}
+
+// Calls deleteBatch until it deletes less than BatchSize snippets, and
+// returns how many were deleted.
+func (r *Reaper) deleteBatches(ctx context.Context, deleteBatch func(context.Context, time.Time, int) (int, error), before time.Time) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		n, err := deleteBatch(ctx, before, r.BatchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < r.BatchSize {
+			break
+		}
+	}
+	return total, ctx.Err()
+}
@@ -1,12 +1,19 @@
 package server
 
 import (
+	"fmt"
 	"html/template"
 	"log"
 	"path/filepath"
+	"regexp"
+	"snippetbox/pkg/diff"
 	"snippetbox/pkg/forms"
+	"snippetbox/pkg/highlight"
 	"snippetbox/pkg/models"
+	"sort"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type templateData struct {
@@ -17,6 +24,31 @@ type templateData struct {
 	Form              *forms.Form
 	Snippet           *models.Snippet
 	Snippets          []*models.Snippet
+	Revisions         []*models.Revision
+	// The public forks of the snippet, see SnippetStore.Forks.
+	Forks []*models.Snippet
+	// Links to the pages of a snippet listing, empty when there is none.
+	NextPage, FirstPage string
+	// The author the snippet listing is filtered on.
+	Author *models.User
+	// The tag the snippet listing is filtered on.
+	Tag string
+	// The words searched for, see models.SearchTerms.
+	Terms []string
+	// The revisions being compared and the hunks of their diff.
+	From, To *models.Revision
+	Diff     []diff.Hunk
+	// The retention policy of the expiry fields, see Application.MaxExpiry.
+	// MaxExpiry is empty when there is no limit.
+	NeverExpire bool
+	MaxExpiry   string
+	// How long deleted snippets stay in the trash, see
+	// Application.TrashRetention.
+	TrashRetention string
+	// The API tokens of the user. NewToken is the one just created, the
+	// only time it is shown.
+	Tokens   []*models.Token
+	NewToken string
 }
 
 // Creates and parses template files, then puts them to a cache.
@@ -66,13 +98,126 @@ func HumanDate(t time.Time) string {
 	if t.IsZero() {
 		return ""
 	}
+	if !t.Before(models.Never) {
+		return "Never"
+	}
 
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// Escapes text and wraps every occurrence of the terms in <mark>, ignoring
+// case.
+func Highlight(text string, terms []string) template.HTML {
+	re := termsRegexp(terms)
+	if re == nil {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range re.FindAllStringIndex(text, -1) {
+		b.WriteString(template.HTMLEscapeString(text[last:match[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[match[0]:match[1]]))
+		b.WriteString("</mark>")
+		last = match[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+	return template.HTML(b.String())
+}
+
+// Returns about size runes of text around the first occurrence of the
+// terms, or the start of text when none of them occur.
+func Excerpt(text string, terms []string, size int) string {
+	runes := []rune(text)
+	if len(runes) <= size {
+		return text
+	}
+
+	start := 0
+	if re := termsRegexp(terms); re != nil {
+		if match := re.FindStringIndex(text); match != nil {
+			// Show a third of the excerpt before the match.
+			start = utf8.RuneCountInString(text[:match[0]]) - size/3
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > len(runes)-size {
+		start = len(runes) - size
+	}
+
+	excerpt := string(runes[start : start+size])
+	if start > 0 {
+		excerpt = "…" + excerpt
+	}
+	if start+size < len(runes) {
+		excerpt += "…"
+	}
+	return excerpt
+}
+
+// Highlights the syntax of code written in the named language. Every line
+// gets a number linking to its own anchor, #L1 for the first one.
+func HighlightCode(code, language string) template.HTML {
+	code = strings.TrimSuffix(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+
+	var b strings.Builder
+	line := 1
+	startLine := func() {
+		fmt.Fprintf(&b, "<span class='line' id='L%d'><a href='#L%d'>%d</a>", line, line, line)
+	}
+	b.WriteString("<pre class='code'><code>")
+	startLine()
+	for _, token := range highlight.Lookup(language).Tokenize(code) {
+		for i, text := range strings.Split(token.Text, "\n") {
+			if i > 0 {
+				// Tokens spanning several lines are split so that every
+				// line stays a separate element.
+				b.WriteString("</span>\n")
+				line++
+				startLine()
+			}
+			if text == "" {
+				continue
+			}
+			if token.Kind == highlight.Text {
+				b.WriteString(template.HTMLEscapeString(text))
+			} else {
+				fmt.Fprintf(&b, "<span class='%s'>%s</span>", token.Kind, template.HTMLEscapeString(text))
+			}
+		}
+	}
+	b.WriteString("</span></code></pre>")
+	return template.HTML(b.String())
+}
+
+// Returns a case insensitive regexp matching any of the terms, nil when
+// there are none.
+func termsRegexp(terms []string) *regexp.Regexp {
+	quoted := []string{}
+	for _, term := range terms {
+		if term != "" {
+			quoted = append(quoted, regexp.QuoteMeta(term))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	// Longest first, so that "snippets" wins over "snippet".
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
+
 // Initialize a template.FuncMap object and store it in a global variable. This is
 // essentially a string-keyed map which acts as a lookup between the names of our
 // custom template functions and the functions themselves.
 var functions = template.FuncMap{
-	"humanDate": HumanDate,
+	"humanDate":     HumanDate,
+	"highlight":     Highlight,
+	"excerpt":       Excerpt,
+	"highlightCode": HighlightCode,
+	"language":      highlight.Lookup,
+	"languages":     func() []*highlight.Language { return highlight.Languages },
 }
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttle counts failures per key, such as the wrong passwords entered for
+// a snippet. Once Max failures happened within Window, the key is refused
+// until the oldest of them is older than Window.
+type Throttle struct {
+	Max    int
+	Window time.Duration
+
+	mu       sync.Mutex
+	failures map[int][]time.Time
+}
+
+func NewThrottle(max int, window time.Duration) *Throttle {
+	return &Throttle{Max: max, Window: window, failures: map[int][]time.Time{}}
+}
+
+// Reports whether another attempt is allowed for the key.
+func (t *Throttle) Allow(key int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.recent(key)) < t.Max
+}
+
+// Records a failed attempt for the key.
+func (t *Throttle) Fail(key int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failures[key] = append(t.recent(key), time.Now())
+}
+
+// Returns the failures of the key within the window and forgets the older
+// ones. The caller must hold the lock.
+func (t *Throttle) recent(key int) []time.Time {
+	failures := since(t.failures[key], time.Now().Add(-t.Window))
+	if len(failures) == 0 {
+		delete(t.failures, key)
+		return nil
+	}
+	t.failures[key] = failures
+	return failures
+}
+
+// RateLimiter allows Max requests per key within Window, such as the pastes
+// sent from an IP address. Unlike Throttle, every request counts.
+type RateLimiter struct {
+	Max    int
+	Window time.Duration
+
+	mu        sync.Mutex
+	requests  map[string][]time.Time
+	lastSweep time.Time
+}
+
+func NewRateLimiter(max int, window time.Duration) *RateLimiter {
+	return &RateLimiter{Max: max, Window: window, requests: map[string][]time.Time{}}
+}
+
+// Reports whether another request is allowed for the key, and counts it
+// when it is.
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-l.Window)
+	// The keys are client addresses that may never come back, so those
+	// without requests left in the window are dropped once per window.
+	if l.lastSweep.Before(cutoff) {
+		for k, times := range l.requests {
+			if len(since(times, cutoff)) == 0 {
+				delete(l.requests, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	requests := since(l.requests[key], cutoff)
+	if len(requests) >= l.Max {
+		l.requests[key] = requests
+		return false
+	}
+	l.requests[key] = append(requests, now)
+	return true
+}
+
+// Returns the number of keys with requests being counted.
+func (l *RateLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.requests)
+}
+
+// Returns the times, oldest first, from cutoff on.
+func since(times []time.Time, cutoff time.Time) []time.Time {
+	for len(times) > 0 && times[0].Before(cutoff) {
+		times = times[1:]
+	}
+	return times
+}
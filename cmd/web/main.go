@@ -1,54 +1,160 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
 	"flag"
+	"fmt"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/golangcollege/sessions"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"snippetbox/cmd/server"
+	"snippetbox/pkg/migrations"
+	"snippetbox/pkg/models"
 	"snippetbox/pkg/models/mysql"
+	"snippetbox/pkg/models/postgres"
+	"snippetbox/pkg/models/sqlite"
+	"strings"
+	"syscall"
 	"time"
 )
 
 type flags struct {
 	port   *string
+	driver *string
 	dsn    *string
 	secret *string
+	// The retention policy, see server.Application.
+	neverExpire *bool
+	maxExpiry   *time.Duration
+	// How often expired snippets are deleted, and how long after expiring.
+	reapInterval *time.Duration
+	reapGrace    *time.Duration
+	// How long deleted snippets stay in the trash.
+	trashRetention *time.Duration
+	// The anonymous pastes, see server.Application.
+	paste        *bool
+	pasteAddr    *string
+	pasteMaxSize *int64
+	pasteRate    *int
+	baseURL      *string
 }
 
 func parseUserInputs() *flags {
-	port, dsn, secret := new(string), new(string), new(string)
+	port, driver, dsn, secret := new(string), new(string), new(string), new(string)
+	neverExpire, maxExpiry := new(bool), new(time.Duration)
+	reapInterval, reapGrace, trashRetention := new(time.Duration), new(time.Duration), new(time.Duration)
+	paste, pasteAddr, pasteMaxSize, pasteRate, baseURL := new(bool), new(string), new(int64), new(int), new(string)
 	if !flag.Parsed() {
 		port = flag.String("port", ":4000", "HTTP network address")
-		dsn = flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
+		driver = flag.String("driver", "", "Database driver (mysql, postgres or sqlite). Guessed from -dsn when empty")
+		dsn = flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "Data source name, e.g. a file path such as ./snippetbox.db for sqlite")
 
 		// TODO: Change the secret string to your choice
 		secret = flag.String("secret", "s6Ndh+pPbnzHbS*+9Pk8qGWhTzbpa@ge", "Secret key")
+
+		neverExpire = flag.Bool("allow-never-expire", false, "Let users post snippets that never expire")
+		maxExpiry = flag.Duration("max-expiry", 0, "Longest time a snippet may be kept, e.g. 720h. 0 for no limit")
+
+		reapInterval = flag.Duration("reap-interval", time.Hour, "How often expired snippets are deleted. 0 keeps them")
+		reapGrace = flag.Duration("reap-grace", 7*24*time.Hour, "How long expired snippets are kept before being deleted")
+		trashRetention = flag.Duration("trash-retention", server.DefaultTrashRetention, "How long deleted snippets can be restored from the trash")
+
+		paste = flag.Bool("paste", false, "Accept anonymous plain text pastes on POST /paste")
+		pasteAddr = flag.String("paste-addr", "", "TCP address of the netcat paste listener, e.g. :9999. Empty disables it")
+		pasteMaxSize = flag.Int64("paste-max-size", server.DefaultMaxPasteSize, "Largest paste in bytes")
+		pasteRate = flag.Int("paste-rate", server.DefaultPasteRate, "Pastes allowed per minute and IP address")
+		baseURL = flag.String("base-url", "", "URL the paste URLs start with. The host of the request, or localhost for the paste listener, when empty")
 	}
 
 	appFlags := &flags{
-		port:   port,
-		dsn:    dsn,
-		secret: secret,
+		port:           port,
+		driver:         driver,
+		dsn:            dsn,
+		secret:         secret,
+		neverExpire:    neverExpire,
+		maxExpiry:      maxExpiry,
+		reapInterval:   reapInterval,
+		reapGrace:      reapGrace,
+		trashRetention: trashRetention,
+		paste:          paste,
+		pasteAddr:      pasteAddr,
+		pasteMaxSize:   pasteMaxSize,
+		pasteRate:      pasteRate,
+		baseURL:        baseURL,
 	}
 	flag.Parse()
+	*appFlags.driver = driverFor(*appFlags.driver, *appFlags.dsn)
 	return appFlags
 }
 
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+// Returns the driver to use. An explicit -driver always wins, otherwise a
+// postgres:// URL selects Postgres and anything else falls back to MySQL.
+func driverFor(driver, dsn string) string {
+	if driver != "" {
+		return driver
+	}
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return "postgres"
+	}
+	return "mysql"
+}
+
+func openDB(driver, dsn string) (*sql.DB, error) {
+	driverName := driver
+	if driver == "sqlite" {
+		driverName = "sqlite3"
+	}
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		return nil, err
 	}
 
+	// SQLite only allows a single writer at a time.
+	if driver == "sqlite" {
+		db.SetMaxOpenConns(1)
+	}
+
 	if err = db.Ping(); err != nil {
 		return nil, err
 	}
 	return db, nil
 }
 
+// Returns the snippet, user and API token stores for the selected driver.
+// The schema must already be up to date, see checkSchema().
+func openStores(driver string, db *sql.DB, infoLog, errorLog *log.Logger) (models.SnippetStore, models.UserStore, models.TokenStore, error) {
+	switch driver {
+	case "mysql":
+		snippets, err := mysql.NewSnippetModel(db, infoLog, errorLog)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return snippets, &mysql.UserModel{DB: db}, &mysql.TokenModel{DB: db}, nil
+	case "postgres":
+		snippets, err := postgres.NewSnippetModel(db, infoLog, errorLog)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return snippets, &postgres.UserModel{DB: db}, &postgres.TokenModel{DB: db}, nil
+	case "sqlite":
+		snippets, err := sqlite.NewSnippetModel(db, infoLog, errorLog)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return snippets, &sqlite.UserModel{DB: db}, &sqlite.TokenModel{DB: db}, nil
+	default:
+		return nil, nil, nil, fmt.Errorf("unknown driver %q", driver)
+	}
+}
+
 func setTLSSettings() *tls.Config {
 	return &tls.Config{
 		PreferServerCipherSuites: true,
@@ -56,15 +162,32 @@ func setTLSSettings() *tls.Config {
 	}
 }
 
+// How long the requests in flight get to finish on shutdown.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	flags := parseUserInputs()
 	infoLog, errorLog := server.CreateLoggers()
-	db, err := openDB(*flags.dsn)
+	db, err := openDB(*flags.driver, *flags.dsn)
 	if err != nil {
-		errorLog.Printf("Error Opening DB Connection: %s", err)
+		errorLog.Fatalf("Error Opening DB Connection: %s", err)
 	}
 	defer db.Close()
 
+	migrator, err := migrations.New(db, *flags.driver)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(context.Background(), migrator, flag.Args()[1:], os.Stdout); err != nil {
+			errorLog.Fatal(err)
+		}
+		return
+	}
+	if err := checkSchema(context.Background(), *flags.driver, migrator, infoLog); err != nil {
+		errorLog.Fatal(err)
+	}
+
 	templateCache, err := server.NewTemplateCache("./ui/html/")
 	if err != nil {
 		errorLog.Fatal(err)
@@ -73,25 +196,74 @@ func main() {
 	session := sessions.New([]byte(*flags.secret))
 	session.Lifetime = 12 * time.Hour
 	session.SameSite = http.SameSiteStrictMode
-	snippets, err := mysql.NewSnippetModel(db, infoLog, errorLog)
+	snippets, users, tokens, err := openStores(*flags.driver, db, infoLog, errorLog)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 
 	tlsConfig := setTLSSettings()
+	reaper := server.NewReaper(snippets, *flags.reapInterval, *flags.reapGrace, infoLog, errorLog)
+	reaper.Retention = *flags.trashRetention
+
+	app := &server.Application{
+		Port:           flags.port,
+		InfoLog:        infoLog,
+		ErrorLog:       errorLog,
+		Snippets:       snippets,
+		TemplateCache:  templateCache,
+		Session:        session,
+		TLSConfig:      tlsConfig,
+		Users:          users,
+		Tokens:         tokens,
+		NeverExpire:    *flags.neverExpire,
+		MaxExpiry:      *flags.maxExpiry,
+		TrashRetention: *flags.trashRetention,
+		Paste:          *flags.paste,
+		BaseURL:        *flags.baseURL,
+		MaxPasteSize:   *flags.pasteMaxSize,
+		PasteLimiter:   server.NewRateLimiter(*flags.pasteRate, time.Minute),
+	}
+	server, err := server.CreateServer(app)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
 
-	server, err := server.CreateServer(
-		&server.Application{
-			Port:          flags.port,
-			InfoLog:       infoLog,
-			ErrorLog:      errorLog,
-			Snippets:      snippets,
-			TemplateCache: templateCache,
-			Session:       session,
-			TLSConfig:     tlsConfig,
-			Users:         &mysql.UserModel{DB: db}})
-	if err == nil {
-		infoLog.Printf("Starting server on %s", *flags.port)
-		errorLog.Fatal(server.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem"))
+	// Interrupting the process stops the reaper and lets the requests in
+	// flight finish.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	reaperDone := make(chan struct{})
+	go func() {
+		defer close(reaperDone)
+		if reaper.Interval > 0 {
+			reaper.Run(ctx)
+		}
+	}()
+	if *flags.pasteAddr != "" {
+		listener, err := net.Listen("tcp", *flags.pasteAddr)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		go func() {
+			infoLog.Printf("Accepting pastes on %s", *flags.pasteAddr)
+			if err := app.ServePastes(ctx, listener); err != nil {
+				errorLog.Printf("Paste listener: %s", err)
+			}
+		}()
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			errorLog.Printf("Error Shutting Down: %s", err)
+		}
+	}()
+
+	infoLog.Printf("Starting server on %s", *flags.port)
+	if err := server.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem"); err != http.ErrServerClosed {
+		errorLog.Fatal(err)
 	}
+	<-reaperDone
+	infoLog.Printf("Server stopped")
 }
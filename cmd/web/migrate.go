@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"snippetbox/pkg/migrations"
+	"strconv"
+)
+
+const migrateUsage = "usage: snippetbox [flags] migrate up|down|status|to N"
+
+// Runs the `migrate` subcommand, e.g. `go run ./cmd/web -dsn=... migrate up`.
+func runMigrate(ctx context.Context, m *migrations.Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		if err := m.Up(ctx); err != nil {
+			return err
+		}
+	case "down":
+		if err := m.Down(ctx); err != nil {
+			return err
+		}
+	case "to":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := m.To(ctx, version); err != nil {
+			return err
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%04d  %-30s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "database is at version %d\n", version)
+	return nil
+}
+
+// Refuses to serve against an out-of-date schema. SQLite is meant to be a
+// single binary deployment, so its schema is brought up to date instead.
+func checkSchema(ctx context.Context, driver string, m *migrations.Migrator, infoLog *log.Logger) error {
+	if driver == "sqlite" {
+		if err := m.Up(ctx); err != nil {
+			return err
+		}
+	}
+	if err := m.CheckCurrent(ctx); err != nil {
+		return fmt.Errorf("%w, run `migrate up` first", err)
+	}
+	infoLog.Printf("Database schema is at version %d", m.Latest())
+	return nil
+}
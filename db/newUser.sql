@@ -1,7 +1,8 @@
 -- Creating a new User.
 
 CREATE USER 'web'@'localhost';
-GRANT SELECT, INSERT ON snippetbox.* TO 'web'@'localhost';
+-- CREATE, ALTER, INDEX, DROP and REFERENCES are needed to run `migrate up|down`.
+GRANT SELECT, INSERT, UPDATE, DELETE, CREATE, ALTER, INDEX, DROP, REFERENCES ON snippetbox.* TO 'web'@'localhost';
 
 -- Important: Make sure to swap 'pass' with a password of your own choosing.
 ALTER USER 'web'@'localhost' IDENTIFIED BY 'pass';
\ No newline at end of file
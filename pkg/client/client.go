@@ -0,0 +1,237 @@
+// Package client talks to the JSON API of a Snippetbox server, see
+// cmd/server/api.go. It is used by cmd/cli and can be used by any Go
+// program that posts or reads snippets.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"snippetbox/pkg/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client sends the requests of one user, authenticated by their API token.
+type Client struct {
+	// The address of the server, e.g. https://localhost:4000.
+	BaseURL string
+	// A personal API token, created on the settings page. Only the public
+	// snippets can be read without one.
+	Token      string
+	HTTPClient *http.Client
+}
+
+// Returns a client for the server at baseURL. When caFile isn't empty, the
+// certificates it holds are trusted on top of the system ones, e.g. the
+// self-signed tls/cert.pem written by tls/generate_cert.go.
+func New(baseURL, token, caFile string) (*Client, error) {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	if caFile != "" {
+		pool, err := certPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		httpClient.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}
+	}
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: httpClient,
+	}, nil
+}
+
+// Returns the system certificates along with the ones of the PEM file.
+func certPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("client: no certificate found in %s", caFile)
+	}
+	return pool, nil
+}
+
+// The error answered by the server. Fields holds the messages per field
+// when a snippet is invalid.
+type Error struct {
+	StatusCode int
+	Message    string              `json:"error"`
+	Fields     map[string][]string `json:"fields"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%d %s", e.StatusCode, e.Message)
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		msg += fmt.Sprintf("; %s: %s", field, strings.Join(e.Fields[field], ", "))
+	}
+	return msg
+}
+
+// Reports whether err is an Error with the given status.
+func IsStatus(err error, status int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// The fields of a new or updated snippet, those of the snippet form.
+// Expires is a number of days, "custom" for ExpiresIn, "at" for ExpiresAt
+// or "never". Updates ignore the password and the views.
+type SnippetRequest struct {
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	Language   string   `json:"language,omitempty"`
+	Visibility string   `json:"visibility,omitempty"`
+	Password   string   `json:"password,omitempty"`
+	Expires    string   `json:"expires"`
+	ExpiresIn  string   `json:"expires_in,omitempty"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	Views      int      `json:"views,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+// The filters of List, those of the /snippets page. The zero values don't
+// filter.
+type ListOptions struct {
+	Author int
+	Limit  int
+	// The Next cursor of the previous page.
+	After string
+}
+
+// A page of snippets. Next is empty on the last page.
+type SnippetPage struct {
+	Snippets []*models.Snippet `json:"snippets"`
+	Next     string            `json:"next"`
+}
+
+// Returns a page of public snippets, newest first.
+func (c *Client) List(ctx context.Context, opts ListOptions) (*SnippetPage, error) {
+	query := url.Values{}
+	if opts.Author != 0 {
+		query.Set("author", strconv.Itoa(opts.Author))
+	}
+	if opts.Limit != 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.After != "" {
+		query.Set("after", opts.After)
+	}
+	path := "/api/v1/snippets"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	page := &SnippetPage{}
+	if err := c.do(ctx, http.MethodGet, path, nil, page); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Returns the snippet. Reading a burn-after-reading snippet uses up one of
+// its views.
+func (c *Client) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	snippet := &models.Snippet{}
+	if err := c.do(ctx, http.MethodGet, snippetPath(id), nil, snippet); err != nil {
+		return nil, err
+	}
+	return snippet, nil
+}
+
+// Creates a snippet and returns it as saved.
+func (c *Client) Create(ctx context.Context, req *SnippetRequest) (*models.Snippet, error) {
+	snippet := &models.Snippet{}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/snippets", req, snippet); err != nil {
+		return nil, err
+	}
+	return snippet, nil
+}
+
+// Replaces the snippet and returns it as saved.
+func (c *Client) Update(ctx context.Context, id int, req *SnippetRequest) (*models.Snippet, error) {
+	snippet := &models.Snippet{}
+	if err := c.do(ctx, http.MethodPut, snippetPath(id), req, snippet); err != nil {
+		return nil, err
+	}
+	return snippet, nil
+}
+
+// Moves the snippet to the trash.
+func (c *Client) Delete(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, snippetPath(id), nil, nil)
+}
+
+// Returns the URL of the snippet page.
+func (c *Client) URL(snippet *models.Snippet) string {
+	return c.BaseURL + snippet.Path()
+}
+
+func snippetPath(id int) string {
+	return "/api/v1/snippets/" + strconv.Itoa(id)
+}
+
+// Sends body as JSON and decodes the answer into res, unless it is nil.
+// The error statuses are returned as an *Error.
+func (c *Client) do(ctx context.Context, method, path string, body, res interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil || apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// The server used when none is configured.
+const DefaultServer = "https://localhost:4000"
+
+// The environment variables overriding the config file, see ApplyEnv.
+const (
+	EnvServer = "SNIPPETBOX_SERVER"
+	EnvToken  = "SNIPPETBOX_TOKEN"
+	EnvCAFile = "SNIPPETBOX_CA_FILE"
+)
+
+// The settings of a client, read from a JSON config file such as
+//
+//	{"server": "https://localhost:4000", "token": "sb_...", "ca_file": "cert.pem"}
+type Config struct {
+	Server string `json:"server"`
+	Token  string `json:"token"`
+	// A PEM file of certificates to trust, see New.
+	CAFile string `json:"ca_file"`
+}
+
+// Returns the path of the config file in the user config directory, e.g.
+// ~/.config/snippetbox/cli.json on Linux.
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "snippetbox", "cli.json"), nil
+}
+
+// Reads the config file. A relative ca_file is relative to the directory
+// of the config file.
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := json.Unmarshal(b, config); err != nil {
+		return nil, err
+	}
+	if config.CAFile != "" && !filepath.IsAbs(config.CAFile) {
+		config.CAFile = filepath.Join(filepath.Dir(path), config.CAFile)
+	}
+	return config, nil
+}
+
+// Overrides the settings with the environment variables that are set, and
+// falls back to DefaultServer.
+func (c *Config) ApplyEnv() {
+	for env, setting := range map[string]*string{
+		EnvServer: &c.Server,
+		EnvToken:  &c.Token,
+		EnvCAFile: &c.CAFile,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*setting = v
+		}
+	}
+	if c.Server == "" {
+		c.Server = DefaultServer
+	}
+}
+
+// Returns a client with the settings.
+func (c *Config) Client() (*Client, error) {
+	return New(c.Server, c.Token, c.CAFile)
+}
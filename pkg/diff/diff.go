@@ -0,0 +1,197 @@
+// Package diff compares texts line by line and groups the changes into
+// hunks, the same way `diff -u` does.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Used as a CSS class by the templates.
+func (op Op) String() string {
+	switch op {
+	case Delete:
+		return "delete"
+	case Insert:
+		return "insert"
+	default:
+		return "equal"
+	}
+}
+
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Returns the line prefixed with " ", "-" or "+" like in a unified diff.
+func (l Line) String() string {
+	switch l.Op {
+	case Delete:
+		return "-" + l.Text
+	case Insert:
+		return "+" + l.Text
+	default:
+		return " " + l.Text
+	}
+}
+
+// A run of changes with the unchanged lines surrounding it. The starts are
+// 1-based line numbers in the old and the new text.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []Line
+}
+
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// Compares a and b and returns the hunks of a unified diff with the given
+// number of context lines. It returns no hunks when the texts are equal.
+func Unified(a, b string, context int) []Hunk {
+	lines := Lines(splitLines(a), splitLines(b))
+
+	// Line numbers in the old and the new text at every position.
+	oldPos := make([]int, len(lines))
+	newPos := make([]int, len(lines))
+	o, n := 0, 0
+	for i, l := range lines {
+		oldPos[i], newPos[i] = o, n
+		if l.Op != Insert {
+			o++
+		}
+		if l.Op != Delete {
+			n++
+		}
+	}
+
+	// Keep every change and the context around it. Changes that are close
+	// enough to share context end up in the same hunk.
+	keep := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.Op == Equal {
+			continue
+		}
+		for j := i - context; j <= i+context; j++ {
+			if j >= 0 && j < len(lines) {
+				keep[j] = true
+			}
+		}
+	}
+
+	hunks := []Hunk{}
+	for i := 0; i < len(lines); {
+		if !keep[i] {
+			i++
+			continue
+		}
+		h := Hunk{OldStart: oldPos[i] + 1, NewStart: newPos[i] + 1}
+		for ; i < len(lines) && keep[i]; i++ {
+			h.Lines = append(h.Lines, lines[i])
+			if lines[i].Op != Insert {
+				h.OldLines++
+			}
+			if lines[i].Op != Delete {
+				h.NewLines++
+			}
+		}
+		// An empty range points at the line before it, like diff -u.
+		if h.OldLines == 0 {
+			h.OldStart--
+		}
+		if h.NewLines == 0 {
+			h.NewStart--
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+// Returns the shortest edit script turning a into b, using Myers' O(ND)
+// algorithm.
+func Lines(a, b []string) []Line {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max
+	v := make([]int, 2*max+2)
+	trace := [][]int{}
+
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, offset)
+			}
+		}
+	}
+	return nil
+}
+
+// Walks the trace of Lines() back from the end of both texts.
+func backtrack(trace [][]int, a, b []string, offset int) []Line {
+	lines := []Line{}
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			lines = append(lines, Line{Op: Equal, Text: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				lines = append(lines, Line{Op: Insert, Text: b[y-1]})
+			} else {
+				lines = append(lines, Line{Op: Delete, Text: a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+// Splits on newlines, ignoring the one at the very end and carriage
+// returns left over from browsers submitting textareas.
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -4,13 +4,20 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 	"unicode/utf8"
 )
 
 // Checks for a valid email address
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// Checks for a valid tag: letters and digits, plus a few separators that
+// are common in names like "c++" or "node.js"
+var TagRX = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N}+._-]*$`)
+
 type Form struct {
 	url.Values
 	Errors errors
@@ -77,6 +84,117 @@ func (f *Form) PermittedValues(field string, opts ...string) {
 	f.Errors.Add(field, "This field is invalid")
 }
 
+// Splits a comma or space separated list of tags. The tags are lower cased
+// and duplicates are dropped, keeping the original order
+func Tags(value string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	fields := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	for _, tag := range fields {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Checks that the field holds at most `count` valid tags of at most
+// `length` characters each, see Tags()
+func (f *Form) ValidTags(field string, count, length int) {
+	tags := Tags(f.Get(field))
+	if len(tags) > count {
+		f.Errors.Add(field, fmt.Sprintf("Too many tags (maximum is %d)", count))
+	}
+	for _, tag := range tags {
+		if utf8.RuneCountInString(tag) > length {
+			f.Errors.Add(field, fmt.Sprintf("The tag %q is too long (maximum is %d characters)", tag, length))
+		} else if !TagRX.MatchString(tag) {
+			f.Errors.Add(field, fmt.Sprintf("The tag %q is invalid", tag))
+		}
+	}
+}
+
+// The layout of the values sent by <input type='datetime-local'>
+const DatetimeLayout = "2006-01-02T15:04"
+
+// The longest duration ParseDuration returns, about 292 years
+const maxDuration = time.Duration(1<<63 - 1)
+
+const day = 24 * time.Hour
+
+// Units accepted by ParseDuration on top of those of time.ParseDuration
+var longUnits = map[string]time.Duration{"d": day, "w": 7 * day, "y": 365 * day}
+
+// Parses durations like "90m" and "1h30m", or a whole number of days,
+// weeks or years such as "7d", "2w" and "1y"
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if n := len(value); n > 1 {
+		if unit, ok := longUnits[value[n-1:]]; ok {
+			count, err := strconv.Atoi(value[:n-1])
+			if err != nil || count < 0 || count > int(maxDuration/unit) {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	return time.ParseDuration(value)
+}
+
+// Formats d the way ParseDuration reads it, such as "7d" or "1h30m"
+func FormatDuration(d time.Duration) string {
+	if d >= day && d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// Checks that the field holds a duration between `min` and `max`, see
+// ParseDuration(). A zero `max` leaves the duration unbounded
+func (f *Form) DurationBetween(field string, min, max time.Duration) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	d, err := ParseDuration(value)
+	switch {
+	case err != nil:
+		f.Errors.Add(field, "This field must be a duration such as 90m, 12h or 7d")
+	case d < min:
+		f.Errors.Add(field, fmt.Sprintf("This duration is too short (minimum is %s)", FormatDuration(min)))
+	case max > 0 && d > max:
+		f.Errors.Add(field, fmt.Sprintf("This duration is too long (maximum is %s)", FormatDuration(max)))
+	}
+}
+
+// Checks that the field holds a UTC datetime in DatetimeLayout between
+// `min` and `max`. A zero `max` leaves the datetime unbounded
+func (f *Form) DatetimeBetween(field string, min, max time.Time) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	t, err := time.ParseInLocation(DatetimeLayout, value, time.UTC)
+	switch {
+	case err != nil:
+		f.Errors.Add(field, "This field must be a date and time")
+	case t.Before(min):
+		f.Errors.Add(field, fmt.Sprintf("This date is too early (minimum is %s)", min.UTC().Format(DatetimeLayout)))
+	case !max.IsZero() && t.After(max):
+		f.Errors.Add(field, fmt.Sprintf("This date is too late (maximum is %s)", max.UTC().Format(DatetimeLayout)))
+	}
+}
+
 func (f *Form) Valid() bool {
 	return len(f.Errors) == 0
 }
@@ -0,0 +1,330 @@
+// Package highlight splits source code into tokens for syntax
+// highlighting. The lexers only know about keywords, comments, strings and
+// numbers, which is enough to make a snippet easy to read.
+package highlight
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type Kind int
+
+const (
+	Text Kind = iota
+	Keyword
+	String
+	Comment
+	Number
+)
+
+// Used as a CSS class by the templates.
+func (k Kind) String() string {
+	switch k {
+	case Keyword:
+		return "keyword"
+	case String:
+		return "string"
+	case Comment:
+		return "comment"
+	case Number:
+		return "number"
+	default:
+		return "text"
+	}
+}
+
+type Token struct {
+	Kind Kind
+	Text string
+}
+
+type Language struct {
+	// Name is stored with the snippet, Label is shown in the forms.
+	Name, Label string
+	// The file extension of downloads, with the leading dot.
+	Extension string
+
+	keywords map[string]bool
+	// SQL keywords are written in any case.
+	ignoreCase   bool
+	lineComments []string
+	// Start and end, both empty when the language has none.
+	blockComment [2]string
+	// Every character that starts a string. Backquoted strings are raw and
+	// may span several lines.
+	quotes string
+	// Regexps matching code that is typical for the language, see Detect.
+	hints []*regexp.Regexp
+}
+
+// The name of the language that highlights nothing.
+const Plain = "plaintext"
+
+// Every supported language, in the order of the forms. Detect prefers the
+// languages listed first.
+var Languages = []*Language{
+	{Name: Plain, Label: "Plain text", Extension: ".txt"},
+	{
+		Name:         "go",
+		Label:        "Go",
+		Extension:    ".go",
+		keywords:     words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+		hints:        hints(`(?m)^package \w+$`, `\bfunc (\(\w+ \*?\w+\) )?\w+\(`, `:=`, `\bfmt\.\w+\(`),
+	},
+	{
+		Name:         "python",
+		Label:        "Python",
+		Extension:    ".py",
+		keywords:     words("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield None True False"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+		hints:        hints(`(?m)^\s*def \w+\(.*\):`, `(?m)^(from [\w.]+ )?import [\w.]+(, [\w.]+)*$`, `\bself\.`, `(?m)^#!.*python`, `\belif\b`),
+	},
+	{
+		Name:         "javascript",
+		Label:        "JavaScript",
+		Extension:    ".js",
+		keywords:     words("async await break case catch class const continue default delete do else export extends finally for from function if import in instanceof let new of return switch this throw try typeof var void while yield null undefined true false"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+		hints:        hints(`\bfunction\b`, `\b(const|let) \w+ =`, `=>`, `\bconsole\.log\(`, `(?m)^#!.*node`),
+	},
+	{
+		Name:         "java",
+		Label:        "Java",
+		Extension:    ".java",
+		keywords:     words("abstract boolean break byte case catch char class continue default do double else enum extends final finally float for if implements import instanceof int interface long new package private protected public return short static super switch this throw throws try void while null true false"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+		hints:        hints(`\bpublic (static )?(final )?(class|void|interface)\b`, `\bSystem\.out\.`, `(?m)^import java\.`),
+	},
+	{
+		Name:         "c",
+		Label:        "C",
+		Extension:    ".c",
+		keywords:     words("auto break case char const continue default do double else enum extern float for goto if int long register return short signed sizeof static struct switch typedef union unsigned void volatile while NULL"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+		hints:        hints(`(?m)^#include\b`, `\bprintf\(`, `\bint main\(`, `\bmalloc\(`),
+	},
+	{
+		Name:         "rust",
+		Label:        "Rust",
+		Extension:    ".rs",
+		keywords:     words("as async await break const continue crate else enum extern fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait type unsafe use where while true false"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		// Single quotes are also used by lifetimes, so only double quotes
+		// start a string.
+		quotes: "\"",
+		hints:  hints(`\bfn \w+`, `\blet mut\b`, `\bprintln!`, `(?m)^use \w+::`, `\bimpl\b`),
+	},
+	{
+		Name:         "sql",
+		Label:        "SQL",
+		Extension:    ".sql",
+		keywords:     words("add all alter and as asc between by case column constraint create default delete desc distinct drop else end exists foreign from group having in index inner insert into is join key left like limit not null on or order outer primary references right select set table then union unique update values view when where"),
+		ignoreCase:   true,
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "'",
+		hints:        hints(`(?is)\bselect\b.+\bfrom\b`, `(?i)\binsert into\b`, `(?i)\bcreate table\b`, `(?i)\bupdate \w+ set\b`),
+	},
+	{
+		Name:         "shell",
+		Label:        "Shell",
+		Extension:    ".sh",
+		keywords:     words("case do done elif else esac export fi for function if in local return then until while"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+		hints:        hints(`(?m)^#!.*\b(ba|z)?sh\b`, `(?m)^\s*(echo|export|sudo|cd) `, `(?m)^\s*if \[`, `\$\{\w+\}`),
+	},
+}
+
+func words(s string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+func hints(patterns ...string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		res[i] = regexp.MustCompile(p)
+	}
+	return res
+}
+
+// Returns the names of every language, for form validation.
+func Names() []string {
+	names := make([]string, len(Languages))
+	for i, l := range Languages {
+		names[i] = l.Name
+	}
+	return names
+}
+
+// Returns the language with the given name. Unknown names, including the
+// empty one, return the plain text language.
+func Lookup(name string) *Language {
+	for _, l := range Languages {
+		if l.Name == name {
+			return l
+		}
+	}
+	return Languages[0]
+}
+
+// Returns the name of the language of the file, from its extension. The
+// empty name is returned for unknown extensions, see Detect.
+func ForFile(name string) string {
+	ext := filepath.Ext(name)
+	if ext == "" {
+		return ""
+	}
+	for _, l := range Languages {
+		if l.Extension == ext {
+			return l.Name
+		}
+	}
+	return ""
+}
+
+// Guesses the language of code from the hints matching it. Plain is
+// returned when nothing matches.
+func Detect(code string) string {
+	best, score := Plain, 0
+	for _, l := range Languages {
+		n := 0
+		for _, hint := range l.hints {
+			if hint.MatchString(code) {
+				n++
+			}
+		}
+		if n > score {
+			best, score = l.Name, n
+		}
+	}
+	return best
+}
+
+// Splits code into tokens. Joining their texts gives back code.
+func (l *Language) Tokenize(code string) []Token {
+	if l.Name == Plain {
+		return []Token{{Kind: Text, Text: code}}
+	}
+
+	tokens := []Token{}
+	add := func(kind Kind, text string) {
+		// Merge runs of plain text to keep the markup small.
+		if n := len(tokens); kind == Text && n > 0 && tokens[n-1].Kind == Text {
+			tokens[n-1].Text += text
+			return
+		}
+		tokens = append(tokens, Token{Kind: kind, Text: text})
+	}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		if n := l.comment(rest); n > 0 {
+			add(Comment, rest[:n])
+			i += n
+			continue
+		}
+
+		c := rest[0]
+		switch {
+		case strings.IndexByte(l.quotes, c) >= 0:
+			n := stringLength(rest)
+			add(String, rest[:n])
+			i += n
+		case isDigit(c) && (i == 0 || !isWord(code[i-1])):
+			n := 1
+			for n < len(rest) && (isWord(rest[n]) || rest[n] == '.') {
+				n++
+			}
+			add(Number, rest[:n])
+			i += n
+		case isWord(c):
+			n := 1
+			for n < len(rest) && isWord(rest[n]) {
+				n++
+			}
+			word := rest[:n]
+			if l.isKeyword(word) {
+				add(Keyword, word)
+			} else {
+				add(Text, word)
+			}
+			i += n
+		default:
+			add(Text, rest[:1])
+			i++
+		}
+	}
+	return tokens
+}
+
+// Returns the length of the comment at the start of s, 0 when there is
+// none. Unterminated comments run to the end of s.
+func (l *Language) comment(s string) int {
+	for _, prefix := range l.lineComments {
+		if strings.HasPrefix(s, prefix) {
+			if end := strings.IndexByte(s, '\n'); end >= 0 {
+				return end
+			}
+			return len(s)
+		}
+	}
+	if start, end := l.blockComment[0], l.blockComment[1]; start != "" && strings.HasPrefix(s, start) {
+		if n := strings.Index(s[len(start):], end); n >= 0 {
+			return len(start) + n + len(end)
+		}
+		return len(s)
+	}
+	return 0
+}
+
+func (l *Language) isKeyword(word string) bool {
+	if l.ignoreCase {
+		word = strings.ToLower(word)
+	}
+	return l.keywords[word]
+}
+
+// Returns the length of the string starting with the quote s[0]. Only raw
+// strings continue past the end of the line.
+func stringLength(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == quote:
+			return i + 1
+		case s[i] == '\\' && quote != '`':
+			i++
+		case s[i] == '\n' && quote != '`':
+			return i
+		}
+	}
+	return len(s)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// Reports whether c can be part of an identifier. Bytes of multi-byte
+// runes count as well, so they are never split.
+func isWord(c byte) bool {
+	return c == '_' || isDigit(c) || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80
+}
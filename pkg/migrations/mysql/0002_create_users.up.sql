@@ -1,11 +1,8 @@
-USE snippetbox;
-
-CREATE TABLE users (
+CREATE TABLE IF NOT EXISTS users (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
     name VARCHAR(255) NOT NULL,
     email VARCHAR(255) NOT NULL,
     hashed_password CHAR(60) NOT NULL,
-    created DATETIME NOT NULL
+    created DATETIME NOT NULL,
+    CONSTRAINT users_uc_email UNIQUE (email)
 );
-
-ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
\ No newline at end of file
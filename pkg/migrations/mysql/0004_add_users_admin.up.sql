@@ -0,0 +1,3 @@
+-- Admins may edit and delete every snippet. There is no UI for this, run
+-- UPDATE users SET admin = TRUE WHERE email = '...' to promote a user.
+ALTER TABLE users ADD COLUMN admin BOOLEAN NOT NULL DEFAULT FALSE;
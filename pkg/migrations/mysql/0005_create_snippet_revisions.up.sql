@@ -0,0 +1,18 @@
+-- Every saved version of a snippet. Existing snippets get their current
+-- contents as the first revision.
+CREATE TABLE snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    INDEX idx_snippet_revisions_snippet_id (snippet_id),
+    CONSTRAINT fk_snippet_revisions_snippet_id
+        FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+    CONSTRAINT fk_snippet_revisions_user_id
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+INSERT INTO snippet_revisions (snippet_id, user_id, title, content, created)
+    SELECT id, user_id, title, content, created FROM snippets;
@@ -0,0 +1,2 @@
+-- Used by Search(). The other backends fall back on LIKE.
+ALTER TABLE snippets ADD FULLTEXT INDEX ft_snippets_title_content (title, content);
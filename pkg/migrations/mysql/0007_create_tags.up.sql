@@ -0,0 +1,17 @@
+-- Tags are shared between snippets, snippet_tags links them together.
+CREATE TABLE tags (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(32) NOT NULL,
+    CONSTRAINT tags_uc_name UNIQUE (name)
+);
+
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, tag_id),
+    INDEX idx_snippet_tags_tag_id (tag_id),
+    CONSTRAINT fk_snippet_tags_snippet_id
+        FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+    CONSTRAINT fk_snippet_tags_tag_id
+        FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
@@ -0,0 +1,6 @@
+-- Who can see the snippet, see models.Public. Existing snippets stay public.
+-- The slug is the random part of the URL of unlisted snippets.
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public';
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(32) NULL;
+
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets (slug);
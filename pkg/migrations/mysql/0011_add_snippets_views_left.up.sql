@@ -0,0 +1,12 @@
+-- Views left before a burn-after-reading snippet is deleted, NULL for the
+-- snippets that can be read any number of times. burned_snippets remembers
+-- the deleted ones so that their pages can tell what happened to them.
+ALTER TABLE snippets ADD COLUMN views_left INTEGER NULL;
+
+CREATE TABLE burned_snippets (
+    snippet_id INTEGER NOT NULL PRIMARY KEY,
+    slug VARCHAR(32) NULL,
+    burned DATETIME NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_burned_snippets_slug ON burned_snippets (slug);
@@ -0,0 +1,6 @@
+-- The snippet a fork was copied from. It becomes NULL when the original is
+-- deleted for good.
+ALTER TABLE snippets ADD COLUMN forked_from INTEGER NULL;
+
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_forked_from
+    FOREIGN KEY (forked_from) REFERENCES snippets(id) ON DELETE SET NULL;
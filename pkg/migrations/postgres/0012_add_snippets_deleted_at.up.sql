@@ -0,0 +1,5 @@
+-- Deleted snippets stay in the trash until deleted_at is older than the
+-- retention period. NULL for the snippets that aren't deleted.
+ALTER TABLE snippets ADD COLUMN deleted_at TIMESTAMP NULL;
+
+CREATE INDEX idx_snippets_deleted_at ON snippets (deleted_at);
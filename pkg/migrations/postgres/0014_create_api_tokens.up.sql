@@ -0,0 +1,14 @@
+-- Personal API tokens, see the settings page. Only the SHA-256 hash of each
+-- token is stored. last_used is NULL until the token is used.
+CREATE TABLE api_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    hashed_token CHAR(64) NOT NULL,
+    scope VARCHAR(10) NOT NULL,
+    created TIMESTAMP NOT NULL,
+    last_used TIMESTAMP NULL,
+    CONSTRAINT api_tokens_uc_hashed_token UNIQUE (hashed_token)
+);
+
+CREATE INDEX idx_api_tokens_user_id ON api_tokens(user_id);
@@ -0,0 +1 @@
+-- Only MySQL has a FULLTEXT index, this backend searches with LIKE.
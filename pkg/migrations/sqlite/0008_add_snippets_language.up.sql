@@ -0,0 +1,3 @@
+-- The language the snippet is highlighted as, see pkg/highlight. Existing
+-- snippets are shown as plain text.
+ALTER TABLE snippets ADD COLUMN language VARCHAR(20) NOT NULL DEFAULT 'plaintext';
@@ -0,0 +1,3 @@
+DROP INDEX idx_snippets_slug;
+ALTER TABLE snippets DROP COLUMN slug;
+ALTER TABLE snippets DROP COLUMN visibility;
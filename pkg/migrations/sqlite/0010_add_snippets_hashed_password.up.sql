@@ -0,0 +1,3 @@
+-- The bcrypt hash of the password protecting the snippet, NULL for snippets
+-- anyone may read.
+ALTER TABLE snippets ADD COLUMN hashed_password CHAR(60) NULL;
@@ -0,0 +1,5 @@
+-- The snippet a fork was copied from. It becomes NULL when the original is
+-- deleted for good.
+ALTER TABLE snippets ADD COLUMN forked_from INTEGER NULL REFERENCES snippets(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_snippets_forked_from ON snippets(forked_from);
@@ -0,0 +1,628 @@
+package memory
+
+import (
+	"context"
+	"snippetbox/pkg/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Compile-time check that SnippetModel satisfies models.SnippetStore.
+var _ models.SnippetStore = (*SnippetModel)(nil)
+
+// SnippetModel keeps snippets in memory. It follows the same rules as the
+// MySQL model: expired and deleted snippets are never returned and List()
+// pages through them newest first.
+type SnippetModel struct {
+	// Users is used to look up the author names. It is optional.
+	Users *UserModel
+
+	mu             sync.RWMutex
+	lastID         int
+	lastRevisionID int
+	snippets       map[int]*models.Snippet
+	// Revisions per snippet ID, oldest first.
+	revisions map[int][]*models.Revision
+	// The slugs of the burned snippets by ID, see View.
+	burned map[int]string
+}
+
+func NewSnippetModel() *SnippetModel {
+	return &SnippetModel{
+		snippets:  map[int]*models.Snippet{},
+		revisions: map[int][]*models.Revision{},
+		burned:    map[int]string{},
+	}
+}
+
+// This function takes the author, title, content, language, visibility, password, the time it expires and the views before it burns
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title, content, language, visibility, password string, expires time.Time, maxViews int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+	slug, err := newSlug(visibility)
+	if err != nil {
+		return -1, err
+	}
+	hashedPassword, err := models.HashPassword(password)
+	if err != nil {
+		return -1, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastID++
+	now := time.Now().UTC()
+	m.snippets[m.lastID] = &models.Snippet{
+		ID:             m.lastID,
+		Title:          title,
+		Content:        content,
+		Language:       language,
+		Created:        now,
+		Expires:        expires.UTC(),
+		UserID:         userID,
+		Visibility:     visibility,
+		Slug:           slug,
+		HashedPassword: hashedPassword,
+		ViewsLeft:      maxViews,
+	}
+	m.recordRevision(m.lastID, userID)
+	return m.lastID, nil
+}
+
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.active(id)
+	if !ok || !s.Expires.After(time.Now().UTC()) {
+		return nil, models.ErrNoRecord
+	}
+	return m.copy(s), nil
+}
+
+func (m *SnippetModel) View(ctx context.Context, id int) (*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.active(id)
+	if !ok || !s.Expires.After(time.Now().UTC()) {
+		return nil, models.ErrNoRecord
+	}
+	if s.ViewsLeft == 0 {
+		return m.copy(s), nil
+	}
+
+	s.ViewsLeft--
+	snippet := m.copy(s)
+	if s.ViewsLeft == 0 {
+		snippet.Burned = true
+		m.delete(id)
+		m.burned[id] = s.Slug
+	}
+	return snippet, nil
+}
+
+func (m *SnippetModel) Burned(ctx context.Context, id int, slug string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.burned[id]; ok {
+		return true, nil
+	}
+	for _, burned := range m.burned {
+		if slug != "" && burned == slug {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *SnippetModel) BySlug(ctx context.Context, slug string) (*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now().UTC()
+	for _, s := range m.snippets {
+		if slug != "" && s.Slug == slug && s.Expires.After(now) && s.DeletedAt.IsZero() {
+			return m.copy(s), nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+// Returns a page of unexpired public snippets matching opts, newest first.
+func (m *SnippetModel) List(ctx context.Context, opts models.ListOptions) (*models.SnippetPage, error) {
+	return m.page(ctx, opts, nil)
+}
+
+// Same as List, limited to the snippets containing every word of the query
+// in their title or content, ignoring case.
+func (m *SnippetModel) Search(ctx context.Context, query string, opts models.ListOptions) (*models.SnippetPage, error) {
+	terms := models.SearchTerms(query)
+	if len(terms) == 0 {
+		return &models.SnippetPage{Snippets: []*models.Snippet{}}, nil
+	}
+	return m.page(ctx, opts, func(s *models.Snippet) bool {
+		title, content := strings.ToLower(s.Title), strings.ToLower(s.Content)
+		for _, term := range terms {
+			if !strings.Contains(title, term) && !strings.Contains(content, term) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Runs List() and Search(). match is the condition of the caller, nil to
+// accept every snippet.
+func (m *SnippetModel) page(ctx context.Context, opts models.ListOptions, match func(*models.Snippet) bool) (*models.SnippetPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now().UTC()
+	snippets := []*models.Snippet{}
+	for _, s := range m.snippets {
+		switch {
+		case !s.Expires.After(now),
+			!s.DeletedAt.IsZero(),
+			s.Visibility != models.Public,
+			opts.UserID != 0 && s.UserID != opts.UserID,
+			!opts.CreatedAfter.IsZero() && s.Created.Before(opts.CreatedAfter),
+			!opts.CreatedBefore.IsZero() && !s.Created.Before(opts.CreatedBefore),
+			!opts.ExpiresBefore.IsZero() && !s.Expires.Before(opts.ExpiresBefore),
+			opts.Tag != "" && !hasTag(s, opts.Tag),
+			opts.After != nil && !opts.After.Precedes(s),
+			match != nil && !match(s):
+			continue
+		}
+		snippets = append(snippets, m.copy(s))
+	}
+
+	newestFirst(snippets)
+	page := &models.SnippetPage{Snippets: snippets}
+	if limit := opts.PageSize(); len(snippets) > limit {
+		page.Snippets = snippets[:limit]
+		page.Next = models.CursorFor(page.Snippets[limit-1])
+	}
+	return page, nil
+}
+
+// Returns every snippet of the user, including expired ones but not the
+// deleted ones.
+func (m *SnippetModel) ByUser(ctx context.Context, userID int) ([]*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snippets := []*models.Snippet{}
+	for _, s := range m.snippets {
+		if s.UserID == userID && s.DeletedAt.IsZero() {
+			snippets = append(snippets, m.copy(s))
+		}
+	}
+	newestFirst(snippets)
+	return snippets, nil
+}
+
+// Replaces the title, content, language, visibility and expiry time.
+func (m *SnippetModel) Update(ctx context.Context, id, userID int, title, content, language, visibility string, expires time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	slug, err := newSlug(visibility)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.active(id)
+	if !ok {
+		return models.ErrNoRecord
+	}
+	s.Title = title
+	s.Content = content
+	s.Language = language
+	s.Visibility = visibility
+	if s.Slug == "" {
+		s.Slug = slug
+	}
+	s.Expires = expires.UTC()
+	m.recordRevision(id, userID)
+	return nil
+}
+
+func (m *SnippetModel) Fork(ctx context.Context, id, userID int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.active(id)
+	if !ok || !s.Expires.After(time.Now().UTC()) {
+		return -1, models.ErrNoRecord
+	}
+	slug, err := newSlug(s.Visibility)
+	if err != nil {
+		return -1, err
+	}
+	m.lastID++
+	m.snippets[m.lastID] = &models.Snippet{
+		ID:         m.lastID,
+		Title:      s.Title,
+		Content:    s.Content,
+		Language:   s.Language,
+		Created:    time.Now().UTC(),
+		Expires:    s.Expires,
+		UserID:     userID,
+		Tags:       append([]string(nil), s.Tags...),
+		Visibility: s.Visibility,
+		Slug:       slug,
+		ForkedFrom: id,
+	}
+	m.recordRevision(m.lastID, userID)
+	return m.lastID, nil
+}
+
+func (m *SnippetModel) Forks(ctx context.Context, id int) ([]*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now().UTC()
+	snippets := []*models.Snippet{}
+	for _, s := range m.snippets {
+		if s.ForkedFrom == id && s.DeletedAt.IsZero() && s.Visibility == models.Public && s.Expires.After(now) {
+			snippets = append(snippets, m.copy(s))
+		}
+	}
+	newestFirst(snippets)
+	return snippets, nil
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.active(id)
+	if !ok {
+		return models.ErrNoRecord
+	}
+	s.DeletedAt = time.Now().UTC()
+	return nil
+}
+
+func (m *SnippetModel) Trash(ctx context.Context, userID int) ([]*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snippets := []*models.Snippet{}
+	for _, s := range m.snippets {
+		if !s.DeletedAt.IsZero() && (userID == 0 || s.UserID == userID) {
+			snippets = append(snippets, m.copy(s))
+		}
+	}
+	sort.Slice(snippets, func(i, j int) bool {
+		return snippets[i].DeletedAt.After(snippets[j].DeletedAt)
+	})
+	return snippets, nil
+}
+
+func (m *SnippetModel) Undelete(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[id]
+	if !ok || s.DeletedAt.IsZero() {
+		return models.ErrNoRecord
+	}
+	s.DeletedAt = time.Time{}
+	return nil
+}
+
+func (m *SnippetModel) Purge(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[id]
+	if !ok || s.DeletedAt.IsZero() {
+		return models.ErrNoRecord
+	}
+	m.delete(id)
+	return nil
+}
+
+func (m *SnippetModel) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+	return m.deleteOldest(ctx, limit, func(s *models.Snippet) time.Time {
+		if s.DeletedAt.IsZero() || !s.DeletedAt.Before(before) {
+			return time.Time{}
+		}
+		return s.DeletedAt
+	})
+}
+
+func (m *SnippetModel) DeleteExpired(ctx context.Context, before time.Time, limit int) (int, error) {
+	return m.deleteOldest(ctx, limit, func(s *models.Snippet) time.Time {
+		if !s.Expires.Before(before) {
+			return time.Time{}
+		}
+		return s.Expires
+	})
+}
+
+// Deletes at most limit snippets, in the order of the times returned by
+// since. Snippets for which it returns the zero time are kept.
+func (m *SnippetModel) deleteOldest(ctx context.Context, limit int, since func(*models.Snippet) time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old := []*models.Snippet{}
+	for _, s := range m.snippets {
+		if !since(s).IsZero() {
+			old = append(old, s)
+		}
+	}
+	sort.Slice(old, func(i, j int) bool {
+		return since(old[i]).Before(since(old[j]))
+	})
+	if len(old) > limit {
+		old = old[:limit]
+	}
+	for _, s := range old {
+		m.delete(s.ID)
+	}
+	return len(old), nil
+}
+
+// Returns the revisions of the snippet, newest first.
+func (m *SnippetModel) Revisions(ctx context.Context, snippetID int) ([]*models.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored := m.revisions[snippetID]
+	revisions := make([]*models.Revision, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		revisions = append(revisions, m.copyRevision(stored[i]))
+	}
+	return revisions, nil
+}
+
+func (m *SnippetModel) Revision(ctx context.Context, snippetID, revisionID int) (*models.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, r := range m.revisions[snippetID] {
+		if r.ID == revisionID {
+			return m.copyRevision(r), nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) Restore(ctx context.Context, snippetID, revisionID, userID int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.active(snippetID)
+	if !ok {
+		return models.ErrNoRecord
+	}
+	for _, r := range m.revisions[snippetID] {
+		if r.ID == revisionID {
+			s.Title = r.Title
+			s.Content = r.Content
+			m.recordRevision(snippetID, userID)
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) SetTags(ctx context.Context, snippetID int, tags []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.active(snippetID)
+	if !ok {
+		return models.ErrNoRecord
+	}
+	s.Tags = append([]string(nil), tags...)
+	sort.Strings(s.Tags)
+	return nil
+}
+
+func (m *SnippetModel) SuggestTags(ctx context.Context, prefix string, limit int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now().UTC()
+	counts := map[string]int{}
+	for _, s := range m.snippets {
+		if !s.Expires.After(now) || !s.DeletedAt.IsZero() || s.Visibility != models.Public {
+			continue
+		}
+		for _, tag := range s.Tags {
+			if strings.HasPrefix(tag, prefix) {
+				counts[tag]++
+			}
+		}
+	}
+
+	tags := make([]string, 0, len(counts))
+	for tag := range counts {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if counts[tags[i]] == counts[tags[j]] {
+			return tags[i] < tags[j]
+		}
+		return counts[tags[i]] > counts[tags[j]]
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags, nil
+}
+
+// Returns a new slug for unlisted snippets, see the SQL backends.
+func newSlug(visibility string) (string, error) {
+	if visibility != models.Unlisted {
+		return "", nil
+	}
+	return models.NewSlug()
+}
+
+func hasTag(s *models.Snippet, tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Deletes the snippet for good. Its forks forget where they came from, like
+// ON DELETE SET NULL does in the SQL backends. The caller must hold the
+// write lock.
+func (m *SnippetModel) delete(id int) {
+	delete(m.snippets, id)
+	delete(m.revisions, id)
+	for _, s := range m.snippets {
+		if s.ForkedFrom == id {
+			s.ForkedFrom = 0
+		}
+	}
+}
+
+// Returns the snippet unless it is missing or in the trash. The caller must
+// hold the lock.
+func (m *SnippetModel) active(id int) (*models.Snippet, bool) {
+	s, ok := m.snippets[id]
+	if !ok || !s.DeletedAt.IsZero() {
+		return nil, false
+	}
+	return s, true
+}
+
+// Saves the current title and content of the snippet as a new revision.
+// The caller must hold the write lock.
+func (m *SnippetModel) recordRevision(snippetID, userID int) {
+	s := m.snippets[snippetID]
+	m.lastRevisionID++
+	m.revisions[snippetID] = append(m.revisions[snippetID], &models.Revision{
+		ID:        m.lastRevisionID,
+		SnippetID: snippetID,
+		Title:     s.Title,
+		Content:   s.Content,
+		Created:   time.Now().UTC(),
+		UserID:    userID,
+	})
+}
+
+// Returns a copy of s so callers can't modify the stored snippet, with the
+// author name filled in.
+func (m *SnippetModel) copy(s *models.Snippet) *models.Snippet {
+	snippet := *s
+	snippet.Tags = append([]string(nil), s.Tags...)
+	if m.Users != nil && s.UserID != 0 {
+		if user, err := m.Users.Get(s.UserID); err == nil {
+			snippet.Author = user.Name
+		}
+	}
+	return &snippet
+}
+
+// Same as copy(), for revisions.
+func (m *SnippetModel) copyRevision(r *models.Revision) *models.Revision {
+	revision := *r
+	if m.Users != nil && r.UserID != 0 {
+		if user, err := m.Users.Get(r.UserID); err == nil {
+			revision.Author = user.Name
+		}
+	}
+	return &revision
+}
+
+// Sorts newest first, using the ID as a tie breaker for snippets created
+// within the same instant.
+func newestFirst(snippets []*models.Snippet) {
+	sort.Slice(snippets, func(i, j int) bool {
+		if snippets[i].Created.Equal(snippets[j].Created) {
+			return snippets[i].ID > snippets[j].ID
+		}
+		return snippets[i].Created.After(snippets[j].Created)
+	})
+}
@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"snippetbox/pkg/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Compile-time check that TokenModel satisfies models.TokenStore.
+var _ models.TokenStore = (*TokenModel)(nil)
+
+// TokenModel keeps API tokens in memory, by the hash of the token like the
+// api_tokens table.
+type TokenModel struct {
+	mu     sync.Mutex
+	lastID int
+	tokens map[string]*models.Token
+}
+
+func NewTokenModel() *TokenModel {
+	return &TokenModel{tokens: map[string]*models.Token{}}
+}
+
+func (m *TokenModel) Insert(ctx context.Context, userID int, name, scope string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	token, hash, err := models.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastID++
+	m.tokens[hash] = &models.Token{
+		ID:      m.lastID,
+		UserID:  userID,
+		Name:    name,
+		Scope:   scope,
+		Created: time.Now().UTC(),
+	}
+	return token, nil
+}
+
+func (m *TokenModel) ByUser(ctx context.Context, userID int) ([]*models.Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := []*models.Token{}
+	for _, t := range m.tokens {
+		if t.UserID == userID {
+			c := *t
+			tokens = append(tokens, &c)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID > tokens[j].ID
+	})
+	return tokens, nil
+}
+
+func (m *TokenModel) Revoke(ctx context.Context, id, userID int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for hash, t := range m.tokens {
+		if t.ID == id && t.UserID == userID {
+			delete(m.tokens, hash)
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+// Like the SQL models, the token returned is the one from before it was
+// used.
+func (m *TokenModel) Authenticate(ctx context.Context, token string) (*models.Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tokens[models.HashToken(token)]
+	if !ok {
+		return nil, models.ErrInvalidCredentials
+	}
+	c := *t
+	t.LastUsed = time.Now().UTC()
+	return &c, nil
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"snippetbox/pkg/models"
+	"sync"
+	"time"
+)
+
+// Compile-time check that UserModel satisfies models.UserStore.
+var _ models.UserStore = (*UserModel)(nil)
+
+// UserModel keeps users in memory. Email addresses are unique, just like
+// the users_uc_email constraint in MySQL.
+type UserModel struct {
+	mu     sync.RWMutex
+	lastID int
+	users  map[int]*models.User
+}
+
+func NewUserModel() *UserModel {
+	return &UserModel{users: map[int]*models.User{}}
+}
+
+func (m *UserModel) Insert(name, email, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Email == email {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	m.lastID++
+	m.users[m.lastID] = &models.User{
+		ID:             m.lastID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        time.Now().UTC(),
+	}
+	return nil
+}
+
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	m.mu.RLock()
+	var user *models.User
+	for _, u := range m.users {
+		if u.Email == email {
+			user = u
+			break
+		}
+	}
+	m.mu.RUnlock()
+
+	if user == nil {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return 0, models.ErrInvalidCredentials
+	} else if err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+// Like the MySQL model, the hashed password is never handed out.
+func (m *UserModel) Get(id int) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	return &models.User{ID: u.ID, Name: u.Name, Email: u.Email, Created: u.Created, Admin: u.Admin}, nil
+}
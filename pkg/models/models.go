@@ -1,7 +1,16 @@
 package models
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -9,22 +18,409 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrInvalidCursor      = errors.New("models: invalid cursor")
 )
 
+// The JSON names are those of the API, see cmd/server/api.go.
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID      int    `json:"id"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	// The name of a language of pkg/highlight.
+	Language string    `json:"language"`
+	Created  time.Time `json:"created"`
+	// Never for the snippets that don't expire.
+	Expires time.Time `json:"expires"`
+	// UserID and Author identify who posted the snippet. Both are empty
+	// for snippets posted before authors were recorded.
+	UserID int    `json:"user_id,omitempty"`
+	Author string `json:"author,omitempty"`
+	// Sorted by name. Only Get is guaranteed to fill them in.
+	Tags []string `json:"tags,omitempty"`
+	// One of Public, Unlisted or Private. Slug is the random part of the
+	// URL of unlisted snippets, see Path().
+	Visibility string `json:"visibility"`
+	Slug       string `json:"slug,omitempty"`
+	// The bcrypt hash of the password protecting the snippet, nil when
+	// there is none.
+	HashedPassword []byte `json:"-"`
+	// Burn-after-reading snippets are deleted after ViewsLeft more views,
+	// see SnippetStore.View. It is 0 for the other snippets.
+	ViewsLeft int `json:"views_left,omitempty"`
+	// Set by SnippetStore.View when the view was the last one.
+	Burned bool `json:"burned,omitempty"`
+	// When the snippet was moved to the trash, zero for the other
+	// snippets. Only SnippetStore.Trash returns deleted snippets.
+	DeletedAt time.Time `json:"-"`
+	// The ID of the snippet this one was forked from, 0 when it wasn't
+	// forked or the original was purged. See SnippetStore.Fork.
+	ForkedFrom int `json:"forked_from,omitempty"`
+}
+
+// Who can see a snippet.
+const (
+	// Listed and readable by anyone.
+	Public = "public"
+	// Left out of the listings and only readable through the slug.
+	Unlisted = "unlisted"
+	// Only readable by the author and admins.
+	Private = "private"
+)
+
+// Every visibility, in the order of the forms.
+var Visibilities = []string{Public, Unlisted, Private}
+
+// Returns a random slug for Snippet.Slug, long enough not to be guessed.
+func NewSlug() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Returns the path of the snippet page. Unlisted snippets are only
+// reachable through their slug, so it is the only path given to others.
+func (s *Snippet) Path() string {
+	if s.Visibility == Unlisted && s.Slug != "" {
+		return "/s/" + s.Slug
+	}
+	return "/snippet/" + strconv.Itoa(s.ID)
+}
+
+// Reports whether the snippet asks for a password before showing its
+// content.
+func (s *Snippet) Protected() bool {
+	return len(s.HashedPassword) > 0
+}
+
+// Reports whether password unlocks the protected snippet.
+func (s *Snippet) PasswordMatches(password string) bool {
+	return s.Protected() && bcrypt.CompareHashAndPassword(s.HashedPassword, []byte(password)) == nil
+}
+
+// Returns the hash stored in Snippet.HashedPassword, nil for an empty
+// password. The cost is the same as the one of the user passwords.
+func HashPassword(password string) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), 12)
+}
+
+// Reports whether the user may open the snippet by its ID. Public snippets
+// are open to everyone, the others only to the users who may edit them.
+// Anyone knowing the slug of an unlisted snippet may open it as well.
+func (s *Snippet) VisibleTo(u *User) bool {
+	return s.Visibility == Public || s.EditableBy(u)
+}
+
+// Reports whether the user may edit or delete the snippet, which is only
+// the case for its author and for admins.
+func (s *Snippet) EditableBy(u *User) bool {
+	if u == nil {
+		return false
+	}
+	return u.Admin || (s.UserID != 0 && s.UserID == u.ID)
+}
+
+// The expiry time of the snippets that never expire. Storing a date this
+// far away rather than NULL keeps the expiry checks of the queries simple.
+// MySQL can't store any later date.
+var Never = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// Reports whether the snippet is kept forever, see Never.
+func (s *Snippet) NeverExpires() bool {
+	return !s.Expires.Before(Never)
+}
+
+// Reports whether the snippet is past its expiry time.
+func (s *Snippet) Expired() bool {
+	return !s.Expires.After(time.Now())
+}
+
+// A saved version of a snippet. Every insert, edit and restore adds one.
+type Revision struct {
+	ID        int
+	SnippetID int
+	Title     string
+	Content   string
+	Created   time.Time
+	// UserID and Author identify who saved the revision, see Snippet.
+	UserID int
+	Author string
 }
 
 // Define a new User type. Notice how the field names and types align
-// with the columns in the database `users` table?
+// with the columns in the database `users` table? The JSON names are those
+// of the API, see cmd/server/api.go.
 type User struct {
-	ID             int
-	Name           string
-	Email          string
-	HashedPassword []byte
-	Created        time.Time
+	ID             int       `json:"id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	HashedPassword []byte    `json:"-"`
+	Created        time.Time `json:"created"`
+	Admin          bool      `json:"admin"`
+}
+
+// What an API token may do.
+const (
+	// Only the GET requests of the API.
+	ScopeRead = "read"
+	// Every request of the API.
+	ScopeWrite = "write"
+)
+
+// Every scope, in the order of the forms.
+var Scopes = []string{ScopeRead, ScopeWrite}
+
+// A personal API token, sent as "Authorization: Bearer <token>". Only the
+// hash of the token is stored, see NewToken.
+type Token struct {
+	ID     int
+	UserID int
+	Name   string
+	// One of ScopeRead or ScopeWrite.
+	Scope   string
+	Created time.Time
+	// When the token was last used, zero when it never was.
+	LastUsed time.Time
+}
+
+// Prefixes the API tokens so that they are easy to recognise, in a leaked
+// configuration file for instance.
+const tokenPrefix = "sb_"
+
+// Returns a new random API token and the hash to store, see HashToken.
+func NewToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = tokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// Returns the hex encoded SHA-256 hash of the token. The tokens are random
+// enough that a fast hash is as good as bcrypt, and it can be looked up.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
+)
+
+// Filters and pages the snippets returned by SnippetStore.List. The zero
+// value returns the first page of every unexpired public snippet.
+type ListOptions struct {
+	// Only snippets of this user, 0 for every author.
+	UserID int
+	// Only snippets created in [CreatedAfter, CreatedBefore). Zero times
+	// leave the range open.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Only snippets expiring before this time, zero for every snippet.
+	ExpiresBefore time.Time
+	// Only snippets with this tag, empty for every snippet.
+	Tag string
+	// Zero means DefaultPageSize, see PageSize().
+	Limit int
+	// Where the previous page ended, nil for the first page.
+	After *Cursor
+}
+
+// Returns the limit clamped to [1, MaxPageSize].
+func (o ListOptions) PageSize() int {
+	switch {
+	case o.Limit <= 0:
+		return DefaultPageSize
+	case o.Limit > MaxPageSize:
+		return MaxPageSize
+	default:
+		return o.Limit
+	}
+}
+
+// Cursor points at the last snippet of a page. Snippets are ordered by
+// creation time and then by ID, so the cursor holds both.
+type Cursor struct {
+	Created time.Time
+	ID      int
+}
+
+func CursorFor(s *Snippet) *Cursor {
+	return &Cursor{Created: s.Created, ID: s.ID}
+}
+
+// Reports whether s belongs after the cursor in newest first order.
+func (c *Cursor) Precedes(s *Snippet) bool {
+	return s.Created.Before(c.Created) || (s.Created.Equal(c.Created) && s.ID < c.ID)
+}
+
+// Encodes the cursor for use in URLs.
+func (c *Cursor) String() string {
+	raw := fmt.Sprintf("%d.%d", c.Created.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decodes a cursor made by Cursor.String().
+func ParseCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	nanos, id, found := strings.Cut(string(raw), ".")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	i, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{Created: time.Unix(0, n).UTC(), ID: i}, nil
+}
+
+type SnippetPage struct {
+	Snippets []*Snippet
+	// Where the next page starts, nil on the last page.
+	Next *Cursor
+}
+
+// The most words of a search query that are looked up.
+const MaxSearchTerms = 10
+
+// Splits a search query into lower case words, without duplicates.
+func SearchTerms(query string) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, term := range strings.Fields(strings.ToLower(query)) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		terms = append(terms, term)
+		if len(terms) == MaxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// Returns a LIKE pattern matching term anywhere in a string. The wildcards
+// in term are escaped with a backslash.
+func LikePattern(term string) string {
+	return "%" + likeEscaper.Replace(term) + "%"
+}
+
+// Same as LikePattern, matching strings that start with prefix.
+func LikePrefix(prefix string) string {
+	return likeEscaper.Replace(prefix) + "%"
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+const (
+	// The most tags a snippet can have.
+	MaxTags = 5
+	// The longest tag name, in characters.
+	MaxTagLength = 32
+	// The most tags returned by SnippetStore.SuggestTags.
+	MaxTagSuggestions = 10
+)
+
+// SnippetStore is implemented by every snippet backend. The server only
+// depends on this interface so the storage can be swapped freely.
+type SnippetStore interface {
+	// Unlisted snippets get a new slug, see NewSlug. A non-empty password
+	// protects the snippet and is stored hashed, see HashPassword. A
+	// positive maxViews makes it a burn-after-reading snippet. Pass Never
+	// as expires to keep it forever.
+	Insert(ctx context.Context, userID int, title, content, language, visibility, password string, expires time.Time, maxViews int) (int, error)
+	Get(ctx context.Context, id int) (*Snippet, error)
+	// Same as Get, counting a view of a burn-after-reading snippet. The
+	// views left are decremented in a transaction and the snippet is
+	// deleted along with the last one, which sets Snippet.Burned.
+	View(ctx context.Context, id int) (*Snippet, error)
+	// Reports whether the snippet with the ID or the slug was deleted after
+	// its last view.
+	Burned(ctx context.Context, id int, slug string) (bool, error)
+	// Same as Get, looking the snippet up by its slug.
+	BySlug(ctx context.Context, slug string) (*Snippet, error)
+	// Returns a page of unexpired public snippets, newest first.
+	List(ctx context.Context, opts ListOptions) (*SnippetPage, error)
+	// Same as List, limited to the snippets whose title or content contain
+	// every word of the query, see SearchTerms.
+	Search(ctx context.Context, query string, opts ListOptions) (*SnippetPage, error)
+	// Returns every snippet posted by the user, including expired ones.
+	ByUser(ctx context.Context, userID int) ([]*Snippet, error)
+	// Update and Delete return ErrNoRecord when the snippet doesn't exist.
+	// userID is the user saving the change.
+	// A snippet made unlisted keeps the slug it already has.
+	Update(ctx context.Context, id, userID int, title, content, language, visibility string, expires time.Time) error
+	// Copies the snippet into a new one owned by the user, which records
+	// where it was forked from. The title, content, language, visibility,
+	// expiry and tags are copied, the password and the views left are not.
+	// Returns ErrNoRecord when the snippet doesn't exist.
+	Fork(ctx context.Context, id, userID int) (int, error)
+	// Returns the unexpired public forks of the snippet, newest first.
+	Forks(ctx context.Context, id int) ([]*Snippet, error)
+	// Moves the snippet to the trash. Every other method ignores deleted
+	// snippets until they are undeleted.
+	Delete(ctx context.Context, id int) error
+	// Returns the deleted snippets of the user, or those of every user when
+	// userID is 0, most recently deleted first.
+	Trash(ctx context.Context, userID int) ([]*Snippet, error)
+	// Undelete and Purge return ErrNoRecord when the snippet isn't in the
+	// trash. Purge deletes it for good.
+	Undelete(ctx context.Context, id int) error
+	Purge(ctx context.Context, id int) error
+	// Purges at most limit snippets deleted before the given time, the
+	// ones deleted first. Returns how many were purged.
+	PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error)
+	// Deletes at most limit snippets that expired before the given time,
+	// the ones that expired first, along with their revisions and tags.
+	// Returns how many were deleted.
+	DeleteExpired(ctx context.Context, before time.Time, limit int) (int, error)
+	// Returns the revisions of the snippet, newest first.
+	Revisions(ctx context.Context, snippetID int) ([]*Revision, error)
+	Revision(ctx context.Context, snippetID, revisionID int) (*Revision, error)
+	// Copies the title and content of an older revision back into the
+	// snippet, which is saved as a new revision. The expiry is untouched.
+	Restore(ctx context.Context, snippetID, revisionID, userID int) error
+	// Replaces the tags of the snippet. The names must already be
+	// normalised, see forms.Tags. Returns ErrNoRecord when the snippet
+	// doesn't exist.
+	SetTags(ctx context.Context, snippetID int, tags []string) error
+	// Returns up to limit tags starting with prefix, the ones used by the
+	// most unexpired public snippets first.
+	SuggestTags(ctx context.Context, prefix string, limit int) ([]string, error)
+}
+
+// UserStore is implemented by every user backend.
+type UserStore interface {
+	Insert(name, email, password string) error
+	Authenticate(email, password string) (int, error)
+	Get(id int) (*User, error)
+}
+
+// TokenStore is implemented by every API token backend.
+type TokenStore interface {
+	// Creates a token for the user and returns it. Only its hash is kept,
+	// the token can't be read again.
+	Insert(ctx context.Context, userID int, name, scope string) (string, error)
+	// Returns the tokens of the user, newest first.
+	ByUser(ctx context.Context, userID int) ([]*Token, error)
+	// Deletes the token. Returns ErrNoRecord when the user has no such
+	// token.
+	Revoke(ctx context.Context, id, userID int) error
+	// Returns the token and records that it was used. Returns
+	// ErrInvalidCredentials when there is no such token.
+	Authenticate(ctx context.Context, token string) (*Token, error)
 }
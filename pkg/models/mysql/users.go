@@ -8,6 +8,9 @@ import (
 	"strings"
 )
 
+// Compile-time check that UserModel satisfies models.UserStore.
+var _ models.UserStore = (*UserModel)(nil)
+
 type UserModel struct {
 	DB *sql.DB
 }
@@ -58,8 +61,8 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 
 func (m *UserModel) Get(id int) (*models.User, error) {
 	s := &models.User{}
-	stmt := `SELECT id, name, email, created FROM users WHERE id = ?`
-	err := m.DB.QueryRow(stmt, id).Scan(&s.ID, &s.Name, &s.Email, &s.Created)
+	stmt := `SELECT id, name, email, created, admin FROM users WHERE id = ?`
+	err := m.DB.QueryRow(stmt, id).Scan(&s.ID, &s.Name, &s.Email, &s.Created, &s.Admin)
 	if err == sql.ErrNoRows {
 		return nil, models.ErrNoRecord
 	} else if err != nil {
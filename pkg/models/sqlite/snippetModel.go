@@ -0,0 +1,780 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"snippetbox/pkg/models"
+	"strings"
+	"time"
+)
+
+// Compile-time check that SnippetDatabase satisfies models.SnippetStore.
+var _ models.SnippetStore = (*SnippetDatabase)(nil)
+
+// Every snippet query selects these columns so that the rows can be read
+// with scanSnippet(). The author is optional, hence the LEFT JOIN.
+const snippetSelect = `SELECT s.id, s.title, s.content, s.created, s.expires, s.user_id, u.name, s.language,
+	s.visibility, s.slug, s.hashed_password, s.views_left, s.deleted_at, s.forked_from FROM snippets s LEFT JOIN users u ON u.id = s.user_id`
+
+// Like snippetSelect, for rows read with scanRevision().
+const revisionSelect = `SELECT r.id, r.snippet_id, r.title, r.content, r.created, r.user_id, u.name
+	FROM snippet_revisions r LEFT JOIN users u ON u.id = r.user_id`
+
+// SnippetDatabase is the SQLite counterpart of mysql.SnippetDatabase.
+// UTC_TIMESTAMP() becomes datetime('now') and DATE_ADD() becomes a
+// datetime() modifier, both of which are in UTC.
+type SnippetDatabase struct {
+	db              *sql.DB
+	infoLog         *log.Logger
+	errorLog        *log.Logger
+	InsertStatement *sql.Stmt
+	GetStatement    *sql.Stmt
+}
+
+// NOTE: It is the caller's responsibility to close EACH of the Statements!
+func NewSnippetModel(db *sql.DB, infolog, errorlog *log.Logger) (*SnippetDatabase, error) {
+	snippetModel := &SnippetDatabase{db: db, infoLog: infolog, errorLog: errorlog}
+	ctx := context.Background()
+
+	// Insert Prepared Statement
+	insertStatement, err := db.PrepareContext(ctx, `INSERT INTO snippets (user_id, title, content, language, visibility, slug, hashed_password, views_left, created, expires)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), ?)`)
+	if err != nil {
+		snippetModel.errorLog.Printf("--- Insert(): Error Preparing Statement: %s ---", err)
+		return nil, err
+	}
+
+	// Get Prepared Statement
+	getStatement, err := db.PrepareContext(ctx, snippetSelect+`
+	WHERE s.expires > datetime('now') AND s.deleted_at IS NULL AND s.id = ?`)
+	if err != nil {
+		snippetModel.errorLog.Printf("--- Get(): Error Preparing Statement: %s ---", err)
+		return nil, err
+	}
+
+	snippetModel.InsertStatement = insertStatement
+	snippetModel.GetStatement = getStatement
+	return snippetModel, nil
+}
+
+func (m *SnippetDatabase) Close() {
+	m.db.Close()
+	m.InsertStatement.Close()
+	m.GetStatement.Close()
+}
+
+// Runs fn inside a transaction bound to ctx. The transaction is committed
+// when fn returns nil and rolled back otherwise.
+func (m *SnippetDatabase) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		m.errorLog.Printf("--- WithTx(): Error Beginning Transaction: %s ---", err)
+		return err
+	}
+
+	if err = fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			m.errorLog.Printf("--- WithTx(): Error Rolling Back Transaction: %s ---", rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// Returns a page of unexpired public snippets matching opts, newest first.
+func (m *SnippetDatabase) List(ctx context.Context, opts models.ListOptions) (*models.SnippetPage, error) {
+	return m.page(ctx, opts, nil, nil)
+}
+
+// Returns a page of unexpired public snippets containing every word of the
+// query, newest first. There is no full-text index, the words are looked
+// up with LIKE, which ignores case for ASCII letters only.
+func (m *SnippetDatabase) Search(ctx context.Context, query string, opts models.ListOptions) (*models.SnippetPage, error) {
+	terms := models.SearchTerms(query)
+	if len(terms) == 0 {
+		return &models.SnippetPage{Snippets: []*models.Snippet{}}, nil
+	}
+	where := []string{}
+	args := []interface{}{}
+	for _, term := range terms {
+		pattern := models.LikePattern(term)
+		where = append(where, `(s.title LIKE ? ESCAPE '\' OR s.content LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	return m.page(ctx, opts, where, args)
+}
+
+// Runs the query behind List() and Search(). where and args hold the
+// conditions of the caller, which are combined with the ones of opts. One
+// extra row is fetched to find out whether there is a next page.
+func (m *SnippetDatabase) page(ctx context.Context, opts models.ListOptions, where []string, args []interface{}) (*models.SnippetPage, error) {
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "?"
+	}
+
+	where = append(where, "s.expires > datetime('now')", "s.deleted_at IS NULL", "s.visibility = 'public'")
+	if opts.UserID != 0 {
+		where = append(where, "s.user_id = "+arg(opts.UserID))
+	}
+	if t := opts.CreatedAfter; !t.IsZero() {
+		where = append(where, "s.created >= "+arg(formatTime(t)))
+	}
+	if t := opts.CreatedBefore; !t.IsZero() {
+		where = append(where, "s.created < "+arg(formatTime(t)))
+	}
+	if t := opts.ExpiresBefore; !t.IsZero() {
+		where = append(where, "s.expires < "+arg(formatTime(t)))
+	}
+	if opts.Tag != "" {
+		where = append(where, `s.id IN (SELECT st.snippet_id FROM snippet_tags st
+		JOIN tags t ON t.id = st.tag_id WHERE t.name = `+arg(opts.Tag)+`)`)
+	}
+	if c := opts.After; c != nil {
+		t := c.Created
+		where = append(where, "(s.created < "+arg(formatTime(t))+" OR (s.created = "+arg(formatTime(t))+" AND s.id < "+arg(c.ID)+"))")
+	}
+	limit := opts.PageSize()
+	query := snippetSelect + `
+	WHERE ` + strings.Join(where, " AND ") + `
+	ORDER BY s.created DESC, s.id DESC LIMIT ` + arg(limit+1)
+
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		m.errorLog.Printf("--- page(): Error Querying: %s ---", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			m.errorLog.Printf("--- Error: %s ---", err)
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &models.SnippetPage{Snippets: snippets}
+	if len(snippets) > limit {
+		page.Snippets = snippets[:limit]
+		page.Next = models.CursorFor(page.Snippets[limit-1])
+	}
+	return page, nil
+}
+
+// This function takes the author, title, content, language, visibility,
+// password, the time it expires and the views before it burns.
+// The snippet and its first revision are saved in one transaction.
+func (m *SnippetDatabase) Insert(ctx context.Context, userID int, title, content, language, visibility, password string, expires time.Time, maxViews int) (int, error) {
+	if m.InsertStatement == nil {
+		m.errorLog.Printf("---- Call NewSnippetModel() first----")
+		return -1, errors.New("there is no Insert Statement")
+	}
+	slug, err := newSlug(visibility)
+	if err != nil {
+		return -1, err
+	}
+	hashedPassword, err := models.HashPassword(password)
+	if err != nil {
+		return -1, err
+	}
+
+	errorValue := -1
+	var id int64
+	err = m.WithTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.StmtContext(ctx, m.InsertStatement).ExecContext(ctx, nullableID(userID), title, content, language, visibility, slug, nullableHash(hashedPassword), nullableViews(maxViews), formatTime(expires))
+		if err != nil {
+			return err
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		return recordRevision(ctx, tx, int(id), userID)
+	})
+	if err != nil {
+		m.errorLog.Printf("Error: %s", err)
+		return errorValue, err
+	}
+	return int(id), nil
+}
+
+func (m *SnippetDatabase) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	if m.GetStatement == nil {
+		m.errorLog.Printf("---- Call NewSnippetModel() first----")
+		return nil, errors.New("getStatement does not exist")
+	}
+
+	s, err := scanSnippet(m.GetStatement.QueryRowContext(ctx, id))
+	switch {
+	case err == sql.ErrNoRows:
+		m.errorLog.Printf("--- Error: %s ---", err)
+		return nil, models.ErrNoRecord
+	case err != nil:
+		m.errorLog.Printf("--- Error: %s ---", err)
+		return nil, err
+	}
+
+	if s.Tags, err = m.tags(ctx, m.db, s.ID); err != nil {
+		m.errorLog.Printf("--- Get(): Error Querying Tags: %s ---", err)
+		return nil, err
+	}
+	return s, nil
+}
+
+// Same as Get, looking the snippet up by its slug.
+func (m *SnippetDatabase) BySlug(ctx context.Context, slug string) (*models.Snippet, error) {
+	s, err := scanSnippet(m.db.QueryRowContext(ctx, snippetSelect+`
+	WHERE s.expires > datetime('now') AND s.deleted_at IS NULL AND s.slug = ?`, slug))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		m.errorLog.Printf("--- BySlug(): Error: %s ---", err)
+		return nil, err
+	}
+
+	if s.Tags, err = m.tags(ctx, m.db, s.ID); err != nil {
+		m.errorLog.Printf("--- BySlug(): Error Querying Tags: %s ---", err)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (m *SnippetDatabase) View(ctx context.Context, id int) (*models.Snippet, error) {
+	var s *models.Snippet
+	err := m.WithTx(ctx, func(tx *sql.Tx) error {
+		// The UPDATE locks the row, so concurrent views are counted one
+		// after the other.
+		result, err := tx.ExecContext(ctx, `UPDATE snippets SET views_left = views_left - 1
+		WHERE id = ? AND deleted_at IS NULL AND views_left > 0 AND expires > datetime('now')`, id)
+		if err != nil {
+			return err
+		}
+		counted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		s, err = scanSnippet(tx.QueryRowContext(ctx, snippetSelect+`
+		WHERE s.expires > datetime('now') AND s.deleted_at IS NULL AND s.id = ?`, id))
+		if err == sql.ErrNoRows {
+			return models.ErrNoRecord
+		} else if err != nil {
+			return err
+		}
+		if s.Tags, err = m.tags(ctx, tx, id); err != nil {
+			return err
+		}
+		if counted == 0 || s.ViewsLeft > 0 {
+			return nil
+		}
+
+		s.Burned = true
+		if err = deleteSnippet(ctx, tx, id); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO burned_snippets (snippet_id, slug, burned)
+		VALUES (?, ?, datetime('now'))`, id, sql.NullString{String: s.Slug, Valid: s.Slug != ""})
+		return err
+	})
+	if err == models.ErrNoRecord {
+		return nil, err
+	} else if err != nil {
+		m.errorLog.Printf("--- View(): Error: %s ---", err)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (m *SnippetDatabase) Burned(ctx context.Context, id int, slug string) (bool, error) {
+	var found int
+	err := m.db.QueryRowContext(ctx, `SELECT 1 FROM burned_snippets
+	WHERE snippet_id = ? OR slug = ?`, id, slug).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		m.errorLog.Printf("--- Burned(): Error: %s ---", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// Returns the tags of the snippet sorted by name.
+func (m *SnippetDatabase) tags(ctx context.Context, q queryer, snippetID int) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `SELECT t.name FROM tags t
+	JOIN snippet_tags st ON st.tag_id = t.id WHERE st.snippet_id = ? ORDER BY t.name`, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	return scanNames(rows)
+}
+
+// Returns every snippet of the user, newest first. Expired snippets are
+// included so that authors can still see what they posted.
+func (m *SnippetDatabase) ByUser(ctx context.Context, userID int) ([]*models.Snippet, error) {
+	rows, err := m.db.QueryContext(ctx, snippetSelect+`
+	WHERE s.user_id = ? AND s.deleted_at IS NULL ORDER BY s.created DESC`, userID)
+	if err != nil {
+		m.errorLog.Printf("--- ByUser(): Error Querying: %s ---", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			m.errorLog.Printf("--- Error: %s ---", err)
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+// Replaces the title, content, language, visibility and expiry time of the
+// snippet.
+func (m *SnippetDatabase) Update(ctx context.Context, id, userID int, title, content, language, visibility string, expires time.Time) error {
+	slug, err := newSlug(visibility)
+	if err != nil {
+		return err
+	}
+	err = m.WithTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `UPDATE snippets SET title = ?, content = ?, language = ?,
+		visibility = ?, slug = COALESCE(slug, ?),
+		expires = ? WHERE id = ? AND deleted_at IS NULL`, title, content, language, visibility, slug, formatTime(expires), id)
+		if err != nil {
+			return err
+		}
+		if err = expectAffected(result); err != nil {
+			return err
+		}
+		return recordRevision(ctx, tx, id, userID)
+	})
+	if err != nil && err != models.ErrNoRecord {
+		m.errorLog.Printf("--- Update(): Error: %s ---", err)
+	}
+	return err
+}
+
+// Copies the row with INSERT ... SELECT, so the content never leaves the
+// database. The visibility is read first to know whether the fork needs a
+// slug.
+func (m *SnippetDatabase) Fork(ctx context.Context, id, userID int) (int, error) {
+	var forkID int64
+	err := m.WithTx(ctx, func(tx *sql.Tx) error {
+		var visibility string
+		err := tx.QueryRowContext(ctx, `SELECT visibility FROM snippets
+		WHERE id = ? AND deleted_at IS NULL AND expires > datetime('now')`, id).Scan(&visibility)
+		if err == sql.ErrNoRows {
+			return models.ErrNoRecord
+		} else if err != nil {
+			return err
+		}
+		slug, err := newSlug(visibility)
+		if err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, `INSERT INTO snippets (user_id, title, content, language, visibility, slug, created, expires, forked_from)
+		SELECT ?, title, content, language, visibility, ?, datetime('now'), expires, id FROM snippets WHERE id = ?`, nullableID(userID), slug, id)
+		if err != nil {
+			return err
+		}
+		if forkID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO snippet_tags (snippet_id, tag_id)
+		SELECT ?, tag_id FROM snippet_tags WHERE snippet_id = ?`, forkID, id)
+		if err != nil {
+			return err
+		}
+		return recordRevision(ctx, tx, int(forkID), userID)
+	})
+	if err == models.ErrNoRecord {
+		return -1, err
+	} else if err != nil {
+		m.errorLog.Printf("--- Fork(): Error: %s ---", err)
+		return -1, err
+	}
+	return int(forkID), nil
+}
+
+func (m *SnippetDatabase) Forks(ctx context.Context, id int) ([]*models.Snippet, error) {
+	rows, err := m.db.QueryContext(ctx, snippetSelect+`
+	WHERE s.forked_from = ? AND s.deleted_at IS NULL AND s.visibility = 'public'
+	AND s.expires > datetime('now') ORDER BY s.created DESC, s.id DESC`, id)
+	if err != nil {
+		m.errorLog.Printf("--- Forks(): Error Querying: %s ---", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			m.errorLog.Printf("--- Error: %s ---", err)
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+func (m *SnippetDatabase) Delete(ctx context.Context, id int) error {
+	result, err := m.db.ExecContext(ctx, `UPDATE snippets SET deleted_at = datetime('now')
+	WHERE id = ? AND deleted_at IS NULL`, id)
+	if err != nil {
+		m.errorLog.Printf("--- Delete(): Error: %s ---", err)
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (m *SnippetDatabase) Trash(ctx context.Context, userID int) ([]*models.Snippet, error) {
+	rows, err := m.db.QueryContext(ctx, snippetSelect+`
+	WHERE s.deleted_at IS NOT NULL AND (? = 0 OR s.user_id = ?)
+	ORDER BY s.deleted_at DESC, s.id DESC`, userID, userID)
+	if err != nil {
+		m.errorLog.Printf("--- Trash(): Error Querying: %s ---", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			m.errorLog.Printf("--- Error: %s ---", err)
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+func (m *SnippetDatabase) Undelete(ctx context.Context, id int) error {
+	result, err := m.db.ExecContext(ctx, `UPDATE snippets SET deleted_at = NULL
+	WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		m.errorLog.Printf("--- Undelete(): Error: %s ---", err)
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (m *SnippetDatabase) Purge(ctx context.Context, id int) error {
+	err := m.WithTx(ctx, func(tx *sql.Tx) error {
+		var exists int
+		err := tx.QueryRowContext(ctx, `SELECT 1 FROM snippets WHERE id = ? AND deleted_at IS NOT NULL`, id).Scan(&exists)
+		if err == sql.ErrNoRows {
+			return models.ErrNoRecord
+		} else if err != nil {
+			return err
+		}
+		return deleteSnippet(ctx, tx, id)
+	})
+	if err != nil && err != models.ErrNoRecord {
+		m.errorLog.Printf("--- Purge(): Error: %s ---", err)
+	}
+	return err
+}
+
+// Deletes the snippet along with its revisions and tag links. SQLite
+// doesn't enforce foreign keys unless asked to, so they are removed here
+// instead of relying on ON DELETE CASCADE. Its forks forget where they
+// came from.
+func deleteSnippet(ctx context.Context, tx *sql.Tx, id int) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE snippets SET forked_from = NULL WHERE forked_from = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM snippet_revisions WHERE snippet_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM snippet_tags WHERE snippet_id = ?`, id); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM snippets WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (m *SnippetDatabase) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+	n, err := m.deleteBefore(ctx, "deleted_at", before, limit)
+	if err != nil {
+		m.errorLog.Printf("--- PurgeDeleted(): Error: %s ---", err)
+	}
+	return n, err
+}
+
+func (m *SnippetDatabase) DeleteExpired(ctx context.Context, before time.Time, limit int) (int, error) {
+	n, err := m.deleteBefore(ctx, "expires", before, limit)
+	if err != nil {
+		m.errorLog.Printf("--- DeleteExpired(): Error: %s ---", err)
+	}
+	return n, err
+}
+
+// Uses deleteSnippet on at most limit snippets whose column, expires or
+// deleted_at, is before the given time, oldest first.
+func (m *SnippetDatabase) deleteBefore(ctx context.Context, column string, before time.Time, limit int) (int, error) {
+	deleted := 0
+	err := m.WithTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT id FROM snippets WHERE `+column+` < ?
+		ORDER BY `+column+` LIMIT ?`, formatTime(before), limit)
+		if err != nil {
+			return err
+		}
+		ids := []int{}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if err := deleteSnippet(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+		deleted = len(ids)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+func (m *SnippetDatabase) Revisions(ctx context.Context, snippetID int) ([]*models.Revision, error) {
+	rows, err := m.db.QueryContext(ctx, revisionSelect+`
+	WHERE r.snippet_id = ? ORDER BY r.id DESC`, snippetID)
+	if err != nil {
+		m.errorLog.Printf("--- Revisions(): Error Querying: %s ---", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			m.errorLog.Printf("--- Error: %s ---", err)
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (m *SnippetDatabase) Revision(ctx context.Context, snippetID, revisionID int) (*models.Revision, error) {
+	r, err := scanRevision(m.db.QueryRowContext(ctx, revisionSelect+`
+	WHERE r.snippet_id = ? AND r.id = ?`, snippetID, revisionID))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		m.errorLog.Printf("--- Revision(): Error: %s ---", err)
+		return nil, err
+	}
+	return r, nil
+}
+
+func (m *SnippetDatabase) Restore(ctx context.Context, snippetID, revisionID, userID int) error {
+	err := m.WithTx(ctx, func(tx *sql.Tx) error {
+		var title, content string
+		err := tx.QueryRowContext(ctx, `SELECT title, content FROM snippet_revisions
+		WHERE snippet_id = ? AND id = ?`, snippetID, revisionID).Scan(&title, &content)
+		if err == sql.ErrNoRows {
+			return models.ErrNoRecord
+		} else if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE snippets SET title = ?, content = ? WHERE id = ? AND deleted_at IS NULL`, title, content, snippetID)
+		if err != nil {
+			return err
+		}
+		return recordRevision(ctx, tx, snippetID, userID)
+	})
+	if err != nil && err != models.ErrNoRecord {
+		m.errorLog.Printf("--- Restore(): Error: %s ---", err)
+	}
+	return err
+}
+
+func (m *SnippetDatabase) SetTags(ctx context.Context, snippetID int, tags []string) error {
+	err := m.WithTx(ctx, func(tx *sql.Tx) error {
+		var found int
+		err := tx.QueryRowContext(ctx, `SELECT 1 FROM snippets WHERE id = ? AND deleted_at IS NULL`, snippetID).Scan(&found)
+		if err == sql.ErrNoRows {
+			return models.ErrNoRecord
+		} else if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, `DELETE FROM snippet_tags WHERE snippet_id = ?`, snippetID); err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			if _, err = tx.ExecContext(ctx, `INSERT OR IGNORE INTO tags (name) VALUES (?)`, tag); err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, `INSERT INTO snippet_tags (snippet_id, tag_id)
+			SELECT ?, id FROM tags WHERE name = ?`, snippetID, tag)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && err != models.ErrNoRecord {
+		m.errorLog.Printf("--- SetTags(): Error: %s ---", err)
+	}
+	return err
+}
+
+func (m *SnippetDatabase) SuggestTags(ctx context.Context, prefix string, limit int) ([]string, error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT t.name FROM tags t
+	JOIN snippet_tags st ON st.tag_id = t.id
+	JOIN snippets s ON s.id = st.snippet_id
+	WHERE t.name LIKE ? ESCAPE '\' AND s.expires > datetime('now')
+	AND s.visibility = 'public' AND s.deleted_at IS NULL
+	GROUP BY t.id, t.name ORDER BY COUNT(*) DESC, t.name LIMIT ?`, models.LikePrefix(prefix), limit)
+	if err != nil {
+		m.errorLog.Printf("--- SuggestTags(): Error Querying: %s ---", err)
+		return nil, err
+	}
+	return scanNames(rows)
+}
+
+// Saves the current title and content of the snippet as a new revision.
+func recordRevision(ctx context.Context, tx *sql.Tx, snippetID, userID int) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO snippet_revisions (snippet_id, user_id, title, content, created)
+	SELECT id, ?, title, content, datetime('now') FROM snippets WHERE id = ?`, nullableID(userID), snippetID)
+	return err
+}
+
+// Returns models.ErrNoRecord when the statement didn't touch any row.
+func expectAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// datetime('now') stores the times as text, so the times compared with
+// them must be in the same format.
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// Returns a new slug for unlisted snippets and NULL for the others.
+func newSlug(visibility string) (sql.NullString, error) {
+	if visibility != models.Unlisted {
+		return sql.NullString{}, nil
+	}
+	slug, err := models.NewSlug()
+	return sql.NullString{String: slug, Valid: err == nil}, err
+}
+
+// Snippets without a view limit store NULL.
+func nullableViews(maxViews int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(maxViews), Valid: maxViews > 0}
+}
+
+// Snippets without a password store NULL.
+func nullableHash(hash []byte) sql.NullString {
+	return sql.NullString{String: string(hash), Valid: len(hash) > 0}
+}
+
+// Authors are optional, so a zero ID is stored as NULL.
+func nullableID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Reads a row selected with snippetSelect.
+func scanSnippet(row scanner) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	var userID sql.NullInt64
+	var author, slug sql.NullString
+	var viewsLeft sql.NullInt64
+	var deletedAt sql.NullTime
+	var forkedFrom sql.NullInt64
+	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &userID, &author, &s.Language, &s.Visibility, &slug, &s.HashedPassword, &viewsLeft, &deletedAt, &forkedFrom)
+	if err != nil {
+		return nil, err
+	}
+	s.UserID = int(userID.Int64)
+	s.Author = author.String
+	s.Slug = slug.String
+	s.ViewsLeft = int(viewsLeft.Int64)
+	s.DeletedAt = deletedAt.Time
+	s.ForkedFrom = int(forkedFrom.Int64)
+	return s, nil
+}
+
+// Reads every row of a single name column and closes rows.
+func scanNames(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// Reads a row selected with revisionSelect.
+func scanRevision(row scanner) (*models.Revision, error) {
+	r := &models.Revision{}
+	var userID sql.NullInt64
+	var author sql.NullString
+	err := row.Scan(&r.ID, &r.SnippetID, &r.Title, &r.Content, &r.Created, &userID, &author)
+	if err != nil {
+		return nil, err
+	}
+	r.UserID = int(userID.Int64)
+	r.Author = author.String
+	return r, nil
+}
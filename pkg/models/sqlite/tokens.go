@@ -0,0 +1,86 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"snippetbox/pkg/models"
+)
+
+// Compile-time check that TokenModel satisfies models.TokenStore.
+var _ models.TokenStore = (*TokenModel)(nil)
+
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// The columns read by scanToken.
+const tokenSelect = `SELECT id, user_id, name, scope, created, last_used FROM api_tokens`
+
+func (m *TokenModel) Insert(ctx context.Context, userID int, name, scope string) (string, error) {
+	token, hash, err := models.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO api_tokens (user_id, name, hashed_token, scope, created)
+	VALUES(?, ?, ?, ?, datetime('now'))`
+
+	if _, err := m.DB.ExecContext(ctx, stmt, userID, name, hash, scope); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (m *TokenModel) ByUser(ctx context.Context, userID int) ([]*models.Token, error) {
+	rows, err := m.DB.QueryContext(ctx, tokenSelect+` WHERE user_id = ? ORDER BY created DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.Token{}
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+func (m *TokenModel) Revoke(ctx context.Context, id, userID int) error {
+	result, err := m.DB.ExecContext(ctx, "DELETE FROM api_tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (m *TokenModel) Authenticate(ctx context.Context, token string) (*models.Token, error) {
+	row := m.DB.QueryRowContext(ctx, tokenSelect+` WHERE hashed_token = ?`, models.HashToken(token))
+	t, err := scanToken(row)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrInvalidCredentials
+	} else if err != nil {
+		return nil, err
+	}
+
+	_, err = m.DB.ExecContext(ctx, "UPDATE api_tokens SET last_used = datetime('now') WHERE id = ?", t.ID)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Reads a row selected with tokenSelect.
+func scanToken(row scanner) (*models.Token, error) {
+	t := &models.Token{}
+	var lastUsed sql.NullTime
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &lastUsed)
+	if err != nil {
+		return nil, err
+	}
+	t.LastUsed = lastUsed.Time
+	return t, nil
+}
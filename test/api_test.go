@@ -0,0 +1,257 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/golangcollege/sessions"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"snippetbox/cmd/server"
+	"snippetbox/pkg/models"
+	"snippetbox/pkg/models/memory"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns an application answering the API from the memory stores.
+func newAPIApp(t *testing.T, snippets *memory.SnippetModel, users *memory.UserModel, tokens *memory.TokenModel) *server.Application {
+	t.Helper()
+	templateCache, err := server.NewTemplateCache("../ui/html/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := sessions.New([]byte(*createSession()))
+	session.Lifetime = 12 * time.Hour
+
+	return &server.Application{
+		Port:          &port,
+		InfoLog:       infoLog,
+		ErrorLog:      errorLog,
+		Snippets:      snippets,
+		TemplateCache: templateCache,
+		Session:       session,
+		Users:         users,
+		Tokens:        tokens,
+	}
+}
+
+// Returns a server answering the API from the memory stores.
+func newAPIServer(t *testing.T, snippets *memory.SnippetModel, users *memory.UserModel, tokens *memory.TokenModel) *http.Server {
+	t.Helper()
+	srv, err := server.CreateServer(newAPIApp(t, snippets, users, tokens))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func TestAPISnippets(t *testing.T) {
+	snippets := memory.NewSnippetModel()
+	public, err := snippets.Insert(ctx, 1, "Public snippet", "Content", "go", models.Public, "", days(7), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, snippets.SetTags(ctx, public, []string{"go"}))
+	private, err := snippets.Insert(ctx, 1, "Private snippet", "Content", "", models.Private, "", days(7), 0)
+	assert.NoError(t, err)
+	protected, err := snippets.Insert(ctx, 1, "Protected snippet", "Content", "", models.Public, "s3cret", days(7), 0)
+	assert.NoError(t, err)
+	burn, err := snippets.Insert(ctx, 1, "Burn snippet", "Content", "", models.Public, "", days(7), 1)
+	assert.NoError(t, err)
+	srv := newAPIServer(t, snippets, memory.NewUserModel(), memory.NewTokenModel())
+
+	serve := func(method, path, contentType, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+		response := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(response, request)
+		return response
+	}
+	decodeError := func(t *testing.T, response *httptest.ResponseRecorder) string {
+		assert.Equal(t, "application/json", response.Header().Get("Content-Type"))
+		body := struct{ Error string }{}
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(&body))
+		return body.Error
+	}
+
+	t.Run("List OK Case", func(t *testing.T) {
+		response := serve(http.MethodGet, "/api/v1/snippets?limit=1", "", "")
+		assertStatus(t, response, http.StatusOK)
+		assert.Equal(t, "application/json", response.Header().Get("Content-Type"))
+
+		page := struct {
+			Snippets []map[string]interface{}
+			Next     string
+		}{}
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(&page))
+		assert.Len(t, page.Snippets, 1)
+		assert.NotEmpty(t, page.Next)
+		assert.NotContains(t, page.Snippets[0], "HashedPassword")
+		assert.NotContains(t, page.Snippets[0], "hashed_password")
+	})
+	t.Run("List NOK Case - Bad filter", func(t *testing.T) {
+		response := serve(http.MethodGet, "/api/v1/snippets?author=nobody", "", "")
+		assertStatus(t, response, http.StatusBadRequest)
+		assert.NotEmpty(t, decodeError(t, response))
+	})
+	t.Run("Get OK Case", func(t *testing.T) {
+		response := serve(http.MethodGet, "/api/v1/snippets/"+strconv.Itoa(public), "", "")
+		assertStatus(t, response, http.StatusOK)
+
+		snippet := &models.Snippet{}
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(snippet))
+		assert.Equal(t, public, snippet.ID)
+		assert.Equal(t, "Public snippet", snippet.Title)
+		assert.Equal(t, []string{"go"}, snippet.Tags)
+	})
+	t.Run("Get OK Case - Burned after the last view", func(t *testing.T) {
+		response := serve(http.MethodGet, "/api/v1/snippets/"+strconv.Itoa(burn), "", "")
+		assertStatus(t, response, http.StatusOK)
+		assert.Contains(t, response.Body.String(), `"burned":true`)
+
+		response = serve(http.MethodGet, "/api/v1/snippets/"+strconv.Itoa(burn), "", "")
+		assertStatus(t, response, http.StatusGone)
+		assert.NotEmpty(t, decodeError(t, response))
+	})
+
+	errorTests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"Get NOK Case - Not found", http.MethodGet, "/api/v1/snippets/100", "", "", http.StatusNotFound},
+		{"Get NOK Case - Malformed ID", http.MethodGet, "/api/v1/snippets/abc", "", "", http.StatusBadRequest},
+		{"Get NOK Case - Private", http.MethodGet, "/api/v1/snippets/" + strconv.Itoa(private), "", "", http.StatusNotFound},
+		{"Get NOK Case - Password protected", http.MethodGet, "/api/v1/snippets/" + strconv.Itoa(protected), "", "", http.StatusForbidden},
+		{"Create NOK Case - Not JSON", http.MethodPost, "/api/v1/snippets", "application/x-www-form-urlencoded", "title=Title", http.StatusUnsupportedMediaType},
+		{"Create NOK Case - Not logged in", http.MethodPost, "/api/v1/snippets", "application/json", `{"title": "Title"}`, http.StatusUnauthorized},
+		{"Update NOK Case - Not logged in", http.MethodPut, "/api/v1/snippets/1", "application/json; charset=utf-8", `{"title": "Title"}`, http.StatusUnauthorized},
+		{"Delete NOK Case - Not logged in", http.MethodDelete, "/api/v1/snippets/1", "", "", http.StatusUnauthorized},
+		{"Unknown route", http.MethodGet, "/api/v1/nothing", "", "", http.StatusNotFound},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := serve(tt.method, tt.path, tt.contentType, tt.body)
+			assertStatus(t, response, tt.status)
+			assert.NotEmpty(t, decodeError(t, response))
+		})
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	snippets := memory.NewSnippetModel()
+	private, err := snippets.Insert(ctx, 1, "Private snippet", "Content", "", models.Private, "", days(7), 0)
+	assert.NoError(t, err)
+	users := memory.NewUserModel()
+	assert.NoError(t, users.Insert("Name", "name@example.com", "C0mpl3xPass!"))
+	tokens := memory.NewTokenModel()
+	readToken, err := tokens.Insert(ctx, 1, "Read", models.ScopeRead)
+	assert.NoError(t, err)
+	writeToken, err := tokens.Insert(ctx, 1, "Write", models.ScopeWrite)
+	assert.NoError(t, err)
+	srv := newAPIServer(t, snippets, users, tokens)
+
+	serve := func(method, path, authorization, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		response := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(response, request)
+		return response
+	}
+
+	t.Run("Get OK Case - Private snippet of the token owner", func(t *testing.T) {
+		response := serve(http.MethodGet, "/api/v1/snippets/"+strconv.Itoa(private), "Bearer "+readToken, "")
+		assertStatus(t, response, http.StatusOK)
+	})
+	t.Run("Create OK Case - Write token", func(t *testing.T) {
+		response := serve(http.MethodPost, "/api/v1/snippets", "Bearer "+writeToken, `{"title": "Title", "content": "Content", "expires": "7"}`)
+		assertStatus(t, response, http.StatusCreated)
+
+		snippet := &models.Snippet{}
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(snippet))
+		assert.Equal(t, 1, snippet.UserID)
+
+		response = serve(http.MethodDelete, "/api/v1/snippets/"+strconv.Itoa(snippet.ID), "bearer "+writeToken, "")
+		assertStatus(t, response, http.StatusNoContent)
+	})
+	t.Run("Records last use", func(t *testing.T) {
+		list, err := tokens.ByUser(ctx, 1)
+		assert.NoError(t, err)
+		for _, tok := range list {
+			assert.False(t, tok.LastUsed.IsZero(), tok.Name)
+		}
+	})
+
+	errorTests := []struct {
+		name          string
+		method        string
+		authorization string
+		status        int
+	}{
+		{"Create NOK Case - Read token", http.MethodPost, "Bearer " + readToken, http.StatusForbidden},
+		{"Create NOK Case - Unknown token", http.MethodPost, "Bearer sb_unknown", http.StatusUnauthorized},
+		{"Create NOK Case - Not a bearer token", http.MethodPost, "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := serve(tt.method, "/api/v1/snippets", tt.authorization, `{"title": "Title"}`)
+			assertStatus(t, response, tt.status)
+			if tt.status == http.StatusUnauthorized {
+				assert.NotEmpty(t, response.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	t.Run("Revoked token", func(t *testing.T) {
+		assert.NoError(t, tokens.Revoke(ctx, 1, 1))
+		response := serve(http.MethodGet, "/api/v1/snippets", "Bearer "+readToken, "")
+		assertStatus(t, response, http.StatusUnauthorized)
+	})
+}
+
+func TestAPISession(t *testing.T) {
+	snippets := memory.NewSnippetModel()
+	private, err := snippets.Insert(ctx, 1, "Private snippet", "Content", "", models.Private, "", days(7), 0)
+	assert.NoError(t, err)
+	users := memory.NewUserModel()
+	assert.NoError(t, users.Insert("Name", "name@example.com", "C0mpl3xPass!"))
+	app := newAPIApp(t, snippets, users, memory.NewTokenModel())
+	srv, err := server.CreateServer(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies := logIn(t, app, 1)
+
+	// What a page of another site can make the browser send.
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, nil)
+		request.Header.Set("Origin", "https://attacker.example.com")
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		response := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(response, request)
+		return response
+	}
+
+	t.Run("Get OK Case - Private snippet of the session user", func(t *testing.T) {
+		response := serve(http.MethodGet, "/api/v1/snippets/"+strconv.Itoa(private))
+		assertStatus(t, response, http.StatusOK)
+	})
+	t.Run("Delete NOK Case - Cookie without a token", func(t *testing.T) {
+		response := serve(http.MethodDelete, "/api/v1/snippets/"+strconv.Itoa(private))
+		assertStatus(t, response, http.StatusUnauthorized)
+
+		_, err := snippets.Get(ctx, private)
+		assert.NoError(t, err)
+	})
+}
@@ -13,9 +13,14 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"regexp"
 	"snippetbox/cmd/server"
+	"snippetbox/pkg/models"
+	"snippetbox/pkg/models/memory"
 	"snippetbox/pkg/models/mysql"
+	"strings"
 	"testing"
 	"time"
 )
@@ -27,8 +32,6 @@ var infoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 func TestHomePage(t *testing.T) {
 	db, mock := NewMock()
 	// New mocks due to NewSnippetModel() factory
-	mock.ExpectBegin()
-	_ = mock.ExpectPrepare("SELECT ...") // SELECT for Latest Statement
 	_ = mock.ExpectPrepare("INSERT ...")
 	prep := mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items
 
@@ -66,8 +69,7 @@ func TestHomePage(t *testing.T) {
 		}
 
 		// Adding ExpectPrepare to DB Expectations
-		rows := sqlmock.NewRows([]string{"id", "title", "content", "created", "expires"})
-		rows.AddRow(0, "Title", "Content", time.Now(), "2024-01-24T10:23:42Z")
+		rows := snippetRows(snippetRow{})
 		prep.ExpectQuery().WillReturnRows(rows)
 
 		request := newRequest(http.MethodGet, "")
@@ -96,7 +98,7 @@ func TestHomePage(t *testing.T) {
 		assertStatus(t, response, http.StatusMethodNotAllowed)
 	})
 	t.Run("checking home page NOK Case - DB has no contents", func(t *testing.T) {
-		app.Snippets.Close() // Closing DB so that Internal Server error is triggered
+		repo.Close() // Closing DB so that Internal Server error is triggered
 		server, err := server.CreateServer(app)
 		if err != nil {
 			log.Printf("problem creating server %v", err)
@@ -155,8 +157,6 @@ func TestShowSnippet(t *testing.T) {
 	db, mock := NewMock()
 
 	// New mocks due to NewSnippetModel() factory
-	mock.ExpectBegin()
-	_ = mock.ExpectPrepare("SELECT ...") // SELECT for Latest Statement
 	_ = mock.ExpectPrepare("INSERT ...")
 	prep := mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items
 
@@ -196,12 +196,16 @@ func TestShowSnippet(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		// Adding ExpectPrepare to DB Expectations
-		rows := sqlmock.NewRows([]string{"id", "title", "content", "created", "expires"})
-		rows.AddRow(0, "Title", "Content", time.Now(), "2024-01-24T10:23:42Z")
+		rows := snippetRows(snippetRow{})
 		prep.ExpectQuery().WithArgs(1).WillReturnRows(rows)
+		mock.ExpectQuery("SELECT t.name FROM tags").WithArgs(0).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("go"))
+		forks := snippetRows(snippetRow{ID: 7, Title: "Fork", UserID: 2, Author: "Other", ForkedFrom: 0})
+		mock.ExpectQuery("SELECT (.+) WHERE s.forked_from = \\?").WithArgs(0).WillReturnRows(forks)
 
 		server.Handler.ServeHTTP(response, request)
 		assertStatus(t, response, http.StatusOK)
+		assert.Contains(t, response.Body.String(), "href='/tag/go'")
+		assert.Contains(t, response.Body.String(), "href='/snippet/7'")
 	})
 	t.Run("checking show snippet NOK Case - malformed URL", func(t *testing.T) {
 		server, err := server.CreateServer(app)
@@ -212,9 +216,7 @@ func TestShowSnippet(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		// Adding ExpectPrepare to DB Expectations
-		mock.ExpectBegin()
-		rows := sqlmock.NewRows([]string{"id", "title", "content", "created", "expires"})
-		rows.AddRow(0, "Title", "Content", time.Now(), "2024-01-24T10:23:42Z")
+		rows := snippetRows(snippetRow{})
 		prep.ExpectQuery().WithArgs(0).WillReturnRows(rows)
 
 		server.Handler.ServeHTTP(response, request)
@@ -229,9 +231,7 @@ func TestShowSnippet(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		// Adding ExpectPrepare to DB Expectations
-		mock.ExpectBegin()
-		rows := sqlmock.NewRows([]string{"id", "title", "content", "created", "expires"})
-		rows.AddRow(0, "Title", "Content", time.Now(), "2024-01-24T10:23:42Z")
+		rows := snippetRows(snippetRow{})
 		prep.ExpectQuery().WithArgs(0).WillReturnRows(rows)
 
 		server.Handler.ServeHTTP(response, request)
@@ -246,9 +246,7 @@ func TestShowSnippet(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		// Adding ExpectPrepare to DB Expectations
-		mock.ExpectBegin()
-		rows := sqlmock.NewRows([]string{"id", "title", "content", "created", "expires"})
-		rows.AddRow(0, "Title", "Content", time.Now(), "2024-01-24T10:23:42Z")
+		rows := snippetRows(snippetRow{})
 		prep.ExpectQuery().WithArgs(0).WillReturnRows(rows)
 
 		server.Handler.ServeHTTP(response, request)
@@ -263,15 +261,14 @@ func TestShowSnippet(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		// Adding ExpectPrepare to DB Expectations
-		rows := sqlmock.NewRows([]string{"id", "title", "content", "created", "expires"})
-		rows.AddRow(0, "Title", "Content", time.Now(), "2024-01-24T10:23:42Z")
+		rows := snippetRows(snippetRow{})
 		prep.ExpectQuery().WithArgs(1).WillReturnRows(rows)
 
 		server.Handler.ServeHTTP(response, request)
 		assertStatus(t, response, http.StatusBadRequest)
 	})
 	t.Run("checking show snippet NOK Case - Database returns an Internal Server Error", func(t *testing.T) {
-		app.Snippets.Close() // Closing the DB Connection to mimic Internal Server Error
+		repo.Close() // Closing the DB Connection to mimic Internal Server Error
 		server, err := server.CreateServer(app)
 		if err != nil {
 			log.Printf("problem creating server %v", err)
@@ -280,8 +277,7 @@ func TestShowSnippet(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		// Adding ExpectPrepare to DB Expectations
-		rows := sqlmock.NewRows([]string{"id", "title", "content", "created", "expires"})
-		rows.AddRow(0, "Title", "Content", time.Now(), "2024-01-24T10:23:42Z")
+		rows := snippetRows(snippetRow{})
 		prep.ExpectQuery().WithArgs(1).WillReturnRows(rows)
 
 		server.Handler.ServeHTTP(response, request)
@@ -293,8 +289,6 @@ func TestShowSnippetIDNotFound(t *testing.T) {
 	db, mock := NewMock()
 
 	// New mocks due to NewSnippetModel() factory
-	mock.ExpectBegin()
-	_ = mock.ExpectPrepare("SELECT ...") // SELECT for Latest Statement
 	_ = mock.ExpectPrepare("INSERT ...")
 	prep := mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items
 
@@ -347,9 +341,7 @@ func TestCreateSnippet(t *testing.T) {
 	db, mock := NewMock()
 
 	// New mocks due to NewSnippetModel() factory
-	mock.ExpectBegin()
-	_ = mock.ExpectPrepare("SELECT ...") // SELECT for Latest Statement
-	prep := mock.ExpectPrepare("INSERT INTO snippets \\(title, content, created, expires\\) VALUES\\(\\?, \\?, UTC_TIMESTAMP\\(\\), DATE_ADD\\(UTC_TIMESTAMP\\(\\), INTERVAL \\? DAY\\)\\)")
+	prep := mock.ExpectPrepare("INSERT INTO snippets \\(user_id, title, content, language, visibility, slug, hashed_password, views_left, created, expires\\) VALUES\\(\\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, UTC_TIMESTAMP\\(\\), \\?\\)")
 	_ = mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items
 
 	repo, err := mysql.NewSnippetModel(db, infoLog, errorLog)
@@ -395,6 +387,7 @@ func TestCreateSnippet(t *testing.T) {
 
 		// Adding ExpectPrepare to DB Expectations
 		prep.ExpectExec().WithArgs(
+			1,
 			"Title",
 			"Content",
 			"1",
@@ -426,8 +419,7 @@ func TestCreateSnippet(t *testing.T) {
 		response := httptest.NewRecorder()
 
 		// Adding ExpectPrepare to DB Expectations
-		rows := sqlmock.NewRows([]string{"id", "title", "content", "created", "expires"})
-		rows.AddRow(0, "Title", "Content", "2024-01-23T10:23:42Z", "2024-01-24T10:23:42Z")
+		rows := snippetRows(snippetRow{Created: "2024-01-23T10:23:42Z"})
 		prep.ExpectQuery().WithArgs(1).WillReturnRows(rows)
 
 		server.Handler.ServeHTTP(response, request)
@@ -480,6 +472,7 @@ func TestCreateSnippet(t *testing.T) {
 
 		// Adding ExpectPrepare to DB Expectations
 		prep.ExpectExec().WithArgs(
+			1,
 			tooLongTitle,
 			"Content",
 			"1",
@@ -504,6 +497,7 @@ func TestCreateSnippet(t *testing.T) {
 
 		// Adding ExpectPrepare to DB Expectations
 		prep.ExpectExec().WithArgs(
+			1,
 			blankTitle,
 			"Content",
 			"1",
@@ -528,6 +522,7 @@ func TestCreateSnippet(t *testing.T) {
 
 		// Adding ExpectPrepare to DB Expectations
 		prep.ExpectExec().WithArgs(
+			1,
 			"Title",
 			blankContent,
 			"1",
@@ -552,6 +547,7 @@ func TestCreateSnippet(t *testing.T) {
 
 		// Adding ExpectPrepare to DB Expectations
 		prep.ExpectExec().WithArgs(
+			1,
 			"Title",
 			"Content",
 			blankExpires,
@@ -577,6 +573,7 @@ func TestCreateSnippet(t *testing.T) {
 
 		// Adding ExpectPrepare to DB Expectations
 		prep.ExpectExec().WithArgs(
+			1,
 			"Title",
 			"Content",
 			wrongExpiresValue,
@@ -599,6 +596,7 @@ func TestCreateSnippet(t *testing.T) {
 
 		// Adding ExpectPrepare to DB Expectations
 		prep.ExpectExec().WithArgs(
+			1,
 			"Title",
 			"Content",
 			"1",
@@ -611,7 +609,7 @@ func TestCreateSnippet(t *testing.T) {
 	})
 
 	t.Run("checking create snippet NOK Case - DB is closed so Insert Fails", func(t *testing.T) {
-		app.Snippets.Close() // Closing the database so Insert() fails
+		repo.Close() // Closing the database so Insert() fails
 		server, err := server.CreateServer(app)
 		if err != nil {
 			log.Printf("problem creating server %v", err)
@@ -627,6 +625,7 @@ func TestCreateSnippet(t *testing.T) {
 
 		// Adding ExpectPrepare to DB Expectations
 		prep.ExpectExec().WithArgs(
+			1,
 			"Title",
 			"Content",
 			"1",
@@ -639,10 +638,93 @@ func TestCreateSnippet(t *testing.T) {
 	})
 }
 
+func TestCreateSnippetExpiry(t *testing.T) {
+	templateCache, err := server.NewTemplateCache("../ui/html/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrfToken := regexp.MustCompile(`name='csrf_token' value='([^']+)'`)
+
+	tests := []struct {
+		name        string
+		neverExpire bool
+		expiresAt   string
+		created     bool
+	}{
+		{"OK Case - Before Never", false, "9999-12-30T23:59", true},
+		{"NOK Case - At Never", false, "9999-12-31T00:00", false},
+		{"NOK Case - After Never", false, "9999-12-31T23:59", false},
+		{"OK Case - Never allowed", true, "9999-12-31T00:00", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets := memory.NewSnippetModel()
+			users := memory.NewUserModel()
+			assert.NoError(t, users.Insert("Name", "name@example.com", "C0mpl3xPass!"))
+			session := sessions.New([]byte(*createSession()))
+			session.Lifetime = 12 * time.Hour
+			app := &server.Application{
+				Port:          &port,
+				InfoLog:       infoLog,
+				ErrorLog:      errorLog,
+				Snippets:      snippets,
+				TemplateCache: templateCache,
+				Session:       session,
+				Users:         users,
+				NeverExpire:   tt.neverExpire,
+			}
+			srv, err := server.CreateServer(app)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cookies := logIn(t, app, 1)
+
+			// The form holds the CSRF token to post it with.
+			request := httptest.NewRequest(http.MethodGet, "/snippet/create", nil)
+			for _, cookie := range cookies {
+				request.AddCookie(cookie)
+			}
+			response := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(response, request)
+			assertStatus(t, response, http.StatusOK)
+			token := csrfToken.FindStringSubmatch(response.Body.String())
+			if token == nil {
+				t.Fatal("no CSRF token in the form")
+			}
+			cookies = append(cookies, response.Result().Cookies()...)
+
+			form := url.Values{
+				"csrf_token": {token[1]},
+				"title":      {"Title"},
+				"content":    {"Content"},
+				"expires":    {"at"},
+				"expires_at": {tt.expiresAt},
+			}
+			request = httptest.NewRequest(http.MethodPost, "/snippet/create", strings.NewReader(form.Encode()))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			for _, cookie := range cookies {
+				request.AddCookie(cookie)
+			}
+			response = httptest.NewRecorder()
+			srv.Handler.ServeHTTP(response, request)
+
+			if tt.created {
+				assertStatus(t, response, http.StatusSeeOther)
+				snippet, err := snippets.Get(ctx, 1)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.neverExpire, snippet.NeverExpires())
+			} else {
+				assertStatus(t, response, http.StatusOK)
+				assert.Contains(t, response.Body.String(), "This date is too late")
+				_, err := snippets.Get(ctx, 1)
+				assert.Equal(t, models.ErrNoRecord, err)
+			}
+		})
+	}
+}
+
 func TestCatchAll(t *testing.T) {
 	db, mock := NewMock()
-	mock.ExpectBegin()
-	_ = mock.ExpectPrepare("SELECT ...") // SELECT for Latest Statement
 	_ = mock.ExpectPrepare("INSERT ...")
 	_ = mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items
 
@@ -684,8 +766,6 @@ func TestCatchAll(t *testing.T) {
 
 func TestAuthentication(t *testing.T) {
 	db, mock := NewMock()
-	mock.ExpectBegin()
-	_ = mock.ExpectPrepare("SELECT ...") // SELECT for Latest Statement
 	_ = mock.ExpectPrepare("INSERT ...")
 	_ = mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items
 
@@ -949,6 +1029,21 @@ func newRequest(requestType, str string) *http.Request {
 	return req
 }
 
+// Returns the expiry time of a snippet kept for n days.
+func days(n int) time.Time {
+	return time.Now().UTC().AddDate(0, 0, n)
+}
+
+// Logs the user in and returns the cookies of their session.
+func logIn(t *testing.T, app *server.Application, userID int) []*http.Cookie {
+	t.Helper()
+	response := httptest.NewRecorder()
+	app.Session.Enable(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.Session.Put(r, "userID", userID)
+	})).ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/", nil))
+	return response.Result().Cookies()
+}
+
 func assertStatus(t testing.TB, response *httptest.ResponseRecorder, want int) {
 	t.Helper()
 	got := response.Code
@@ -0,0 +1,326 @@
+package test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"github.com/DATA-DOG/go-sqlmock"
+	sqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"snippetbox/pkg/models"
+	"snippetbox/pkg/models/mysql"
+	"snippetbox/pkg/models/postgres"
+	"testing"
+	"time"
+)
+
+// The SQL backends that are exercised through sqlmock. Every backend has
+// to behave the same way, only the way they talk to the driver differs.
+var sqlBackends = []struct {
+	name           string
+	newSnippets    func(db *sql.DB) (models.SnippetStore, error)
+	newUsers       func(db *sql.DB) models.UserStore
+	expires        driver.Value
+	insertRevision string
+	linkTag        string
+	expectInsert   func(prep *sqlmock.ExpectedPrepare, id int64)
+	duplicateEmail error
+	// How Search("Go +maps") reaches the database.
+	searchQuery string
+	searchArgs  []driver.Value
+}{
+	{
+		name: "mysql",
+		newSnippets: func(db *sql.DB) (models.SnippetStore, error) {
+			return mysql.NewSnippetModel(db, infoLog, errorLog)
+		},
+		newUsers: func(db *sql.DB) models.UserStore {
+			return &mysql.UserModel{DB: db}
+		},
+		expires:        "2024-01-24T10:23:42Z",
+		insertRevision: "INSERT INTO snippet_revisions (.+) SELECT id, \\?, title",
+		linkTag:        "INSERT INTO snippet_tags (.+) SELECT \\?, id FROM tags",
+		expectInsert: func(prep *sqlmock.ExpectedPrepare, id int64) {
+			prep.ExpectExec().WithArgs(1, "Title", "Content", "", models.Public, nil, nil, nil, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(id, 1))
+		},
+		duplicateEmail: &sqlDriver.MySQLError{
+			Number:  1062,
+			Message: "Duplicate entry 'Email' for key 'users_uc_email'",
+		},
+		searchQuery: "WHERE MATCH\\(s.title, s.content\\) AGAINST \\(\\? IN BOOLEAN MODE\\)",
+		searchArgs:  []driver.Value{"+go* +maps*", models.DefaultPageSize + 1},
+	},
+	{
+		name: "postgres",
+		newSnippets: func(db *sql.DB) (models.SnippetStore, error) {
+			return postgres.NewSnippetModel(db, infoLog, errorLog)
+		},
+		newUsers: func(db *sql.DB) models.UserStore {
+			return &postgres.UserModel{DB: db}
+		},
+		expires:        time.Date(2024, 1, 24, 10, 23, 42, 0, time.UTC),
+		insertRevision: "INSERT INTO snippet_revisions (.+) SELECT id, \\$1::INTEGER, title",
+		linkTag:        "INSERT INTO snippet_tags (.+) SELECT \\$1::INTEGER, id FROM tags",
+		expectInsert: func(prep *sqlmock.ExpectedPrepare, id int64) {
+			prep.ExpectQuery().WithArgs(1, "Title", "Content", "", models.Public, nil, nil, nil, sqlmock.AnyArg()).WillReturnRows(
+				sqlmock.NewRows([]string{"id"}).AddRow(id))
+		},
+		duplicateEmail: &pq.Error{
+			Code:       "23505",
+			Constraint: "users_uc_email",
+		},
+		searchQuery: "WHERE \\(s.title ILIKE \\$1 OR s.content ILIKE \\$1\\) AND \\(s.title ILIKE \\$2",
+		searchArgs:  []driver.Value{"%go%", "%+maps%", models.DefaultPageSize + 1},
+	},
+}
+
+func TestSnippetBackends(t *testing.T) {
+	for _, tt := range sqlBackends {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := NewMock()
+			insert := mock.ExpectPrepare("INSERT INTO snippets")
+			get := mock.ExpectPrepare("SELECT (.+) FROM snippets")
+
+			repo, err := tt.newSnippets(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			t.Run("Insert OK Case", func(t *testing.T) {
+				mock.ExpectBegin()
+				tt.expectInsert(insert, 42)
+				mock.ExpectExec(tt.insertRevision).WithArgs(1, 42).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+				id, err := repo.Insert(ctx, 1, "Title", "Content", "", models.Public, "", days(1), 0)
+				assert.NoError(t, err)
+				assert.Equal(t, 42, id)
+			})
+			t.Run("Get OK Case", func(t *testing.T) {
+				rows := snippetRows(snippetRow{ID: 42, Expires: tt.expires})
+				get.ExpectQuery().WithArgs(42).WillReturnRows(rows)
+				mock.ExpectQuery("SELECT t.name FROM tags").WithArgs(42).
+					WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("go").AddRow("web"))
+
+				snippet, err := repo.Get(ctx, 42)
+				assert.NoError(t, err)
+				assert.Equal(t, "Title", snippet.Title)
+				assert.Equal(t, []string{"go", "web"}, snippet.Tags)
+			})
+			t.Run("Get NOK Case - No Record", func(t *testing.T) {
+				get.ExpectQuery().WithArgs(7).WillReturnError(sql.ErrNoRows)
+
+				snippet, err := repo.Get(ctx, 7)
+				assert.Nil(t, snippet)
+				assert.Equal(t, models.ErrNoRecord, err)
+			})
+			t.Run("BySlug OK Case", func(t *testing.T) {
+				rows := snippetRows(snippetRow{ID: 42, Expires: tt.expires, Visibility: "unlisted", Slug: "AbCdEfGhIjKlMnOp"})
+				mock.ExpectQuery("SELECT (.+) FROM snippets (.+) s.slug = ").WithArgs("AbCdEfGhIjKlMnOp").WillReturnRows(rows)
+				mock.ExpectQuery("SELECT t.name FROM tags").WithArgs(42).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+
+				snippet, err := repo.BySlug(ctx, "AbCdEfGhIjKlMnOp")
+				assert.NoError(t, err)
+				assert.Equal(t, "/s/AbCdEfGhIjKlMnOp", snippet.Path())
+			})
+			t.Run("BySlug NOK Case - No Record", func(t *testing.T) {
+				mock.ExpectQuery("SELECT (.+) FROM snippets (.+) s.slug = ").WithArgs("nope").WillReturnError(sql.ErrNoRows)
+
+				_, err := repo.BySlug(ctx, "nope")
+				assert.Equal(t, models.ErrNoRecord, err)
+			})
+			t.Run("List OK Case - Next page", func(t *testing.T) {
+				created := time.Date(2024, 1, 20, 10, 23, 42, 0, time.UTC)
+				rows := snippetRows(
+					snippetRow{ID: 42, Created: created, Expires: tt.expires},
+					snippetRow{ID: 41, Created: created, Expires: tt.expires},
+					snippetRow{ID: 40, Created: created, Expires: tt.expires},
+				)
+				mock.ExpectQuery("SELECT (.+) FROM snippets (.+) ORDER BY s.created DESC, s.id DESC").
+					WithArgs(1, 3).WillReturnRows(rows)
+
+				page, err := repo.List(ctx, models.ListOptions{UserID: 1, Limit: 2})
+				assert.NoError(t, err)
+				assert.Len(t, page.Snippets, 2)
+				assert.Equal(t, "Name", page.Snippets[0].Author)
+				assert.Equal(t, &models.Cursor{Created: created, ID: 41}, page.Next)
+			})
+			t.Run("Search OK Case", func(t *testing.T) {
+				rows := snippetRows(snippetRow{ID: 42, Title: "Go maps", Expires: tt.expires})
+				mock.ExpectQuery(tt.searchQuery).WithArgs(tt.searchArgs...).WillReturnRows(rows)
+
+				page, err := repo.Search(ctx, "Go +maps", models.ListOptions{})
+				assert.NoError(t, err)
+				assert.Len(t, page.Snippets, 1)
+			})
+			t.Run("Search OK Case - No words", func(t *testing.T) {
+				page, err := repo.Search(ctx, " ", models.ListOptions{})
+				assert.NoError(t, err)
+				assert.Empty(t, page.Snippets)
+			})
+			t.Run("ByUser OK Case", func(t *testing.T) {
+				rows := snippetRows(
+					snippetRow{ID: 42, Expires: tt.expires},
+					snippetRow{ID: 41, Title: "Old", Expires: tt.expires},
+				)
+				mock.ExpectQuery("SELECT (.+) WHERE s.user_id").WithArgs(1).WillReturnRows(rows)
+
+				snippets, err := repo.ByUser(ctx, 1)
+				assert.NoError(t, err)
+				assert.Len(t, snippets, 2)
+				assert.Equal(t, 1, snippets[1].UserID)
+			})
+			t.Run("Update OK Case", func(t *testing.T) {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE snippets").WithArgs("New", "Changed", "", models.Public, nil, sqlmock.AnyArg(), 42).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(tt.insertRevision).WithArgs(1, 42).WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit()
+
+				assert.NoError(t, repo.Update(ctx, 42, 1, "New", "Changed", "", models.Public, days(7)))
+			})
+			t.Run("SetTags OK Case", func(t *testing.T) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT 1 FROM snippets").WithArgs(42).
+					WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+				mock.ExpectExec("DELETE FROM snippet_tags").WithArgs(42).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT (.*)INTO tags").WithArgs("go").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(tt.linkTag).WithArgs(42, "go").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+
+				assert.NoError(t, repo.SetTags(ctx, 42, []string{"go"}))
+			})
+			t.Run("SetTags NOK Case - No Record", func(t *testing.T) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT 1 FROM snippets").WithArgs(7).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+
+				assert.Equal(t, models.ErrNoRecord, repo.SetTags(ctx, 7, []string{"go"}))
+			})
+			t.Run("SuggestTags OK Case", func(t *testing.T) {
+				mock.ExpectQuery("SELECT t.name FROM tags (.+) LIKE").WithArgs("g\\_%", 5).
+					WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("g_o"))
+
+				tags, err := repo.SuggestTags(ctx, "g_", 5)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"g_o"}, tags)
+			})
+			t.Run("Fork NOK Case - No Record", func(t *testing.T) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT visibility FROM snippets").WithArgs(7).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+
+				_, err := repo.Fork(ctx, 7, 1)
+				assert.Equal(t, models.ErrNoRecord, err)
+			})
+			t.Run("Delete OK Case - Moved to the trash", func(t *testing.T) {
+				mock.ExpectExec("UPDATE snippets SET deleted_at (.+) AND deleted_at IS NULL").WithArgs(42).WillReturnResult(sqlmock.NewResult(0, 1))
+
+				assert.NoError(t, repo.Delete(ctx, 42))
+			})
+			t.Run("Trash OK Case", func(t *testing.T) {
+				rows := snippetRows(snippetRow{ID: 42, Expires: tt.expires, DeletedAt: time.Now()})
+				mock.ExpectQuery("SELECT (.+) WHERE s.deleted_at IS NOT NULL").WillReturnRows(rows)
+
+				trash, err := repo.Trash(ctx, 1)
+				assert.NoError(t, err)
+				if assert.Len(t, trash, 1) {
+					assert.False(t, trash[0].DeletedAt.IsZero())
+				}
+			})
+			t.Run("Undelete OK Case", func(t *testing.T) {
+				mock.ExpectExec("UPDATE snippets SET deleted_at = NULL (.+) AND deleted_at IS NOT NULL").WithArgs(42).WillReturnResult(sqlmock.NewResult(0, 1))
+
+				assert.NoError(t, repo.Undelete(ctx, 42))
+			})
+			t.Run("Purge NOK Case - Not in the trash", func(t *testing.T) {
+				mock.ExpectExec("DELETE FROM snippets WHERE (.+) AND deleted_at IS NOT NULL").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 0))
+
+				assert.Equal(t, models.ErrNoRecord, repo.Purge(ctx, 7))
+			})
+			t.Run("PurgeDeleted OK Case", func(t *testing.T) {
+				mock.ExpectExec("DELETE FROM snippets WHERE (.*)deleted_at <").WithArgs(sqlmock.AnyArg(), 100).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+
+				n, err := repo.PurgeDeleted(ctx, time.Now(), 100)
+				assert.NoError(t, err)
+				assert.Equal(t, 2, n)
+			})
+			t.Run("DeleteExpired OK Case", func(t *testing.T) {
+				mock.ExpectExec("DELETE FROM snippets WHERE (.*)expires <").WithArgs(sqlmock.AnyArg(), 100).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+
+				n, err := repo.DeleteExpired(ctx, time.Now(), 100)
+				assert.NoError(t, err)
+				assert.Equal(t, 3, n)
+			})
+			t.Run("Delete NOK Case - No Record", func(t *testing.T) {
+				mock.ExpectExec("UPDATE snippets SET deleted_at").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 0))
+
+				assert.Equal(t, models.ErrNoRecord, repo.Delete(ctx, 7))
+			})
+			t.Run("Update NOK Case - Rolled back", func(t *testing.T) {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE snippets").WithArgs("New", "Changed", "", models.Public, nil, sqlmock.AnyArg(), 42).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(tt.insertRevision).WithArgs(1, 42).WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+
+				assert.Equal(t, sql.ErrConnDone, repo.Update(ctx, 42, 1, "New", "Changed", "", models.Public, days(7)))
+			})
+			t.Run("Revisions OK Case", func(t *testing.T) {
+				rows := sqlmock.NewRows([]string{"id", "snippet_id", "title", "content", "created", "user_id", "name"})
+				rows.AddRow(2, 42, "New", "Changed", time.Now(), 1, "Name")
+				rows.AddRow(1, 42, "Title", "Content", time.Now(), nil, nil)
+				mock.ExpectQuery("SELECT (.+) FROM snippet_revisions (.+) WHERE r.snippet_id").WithArgs(42).WillReturnRows(rows)
+
+				revisions, err := repo.Revisions(ctx, 42)
+				assert.NoError(t, err)
+				assert.Len(t, revisions, 2)
+				assert.Equal(t, "Name", revisions[0].Author)
+				assert.Equal(t, "", revisions[1].Author)
+			})
+			t.Run("Revision NOK Case - No Record", func(t *testing.T) {
+				mock.ExpectQuery("SELECT (.+) FROM snippet_revisions").WithArgs(42, 9).WillReturnError(sql.ErrNoRows)
+
+				revision, err := repo.Revision(ctx, 42, 9)
+				assert.Nil(t, revision)
+				assert.Equal(t, models.ErrNoRecord, err)
+			})
+			t.Run("Restore OK Case", func(t *testing.T) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT title, content FROM snippet_revisions").WithArgs(42, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"title", "content"}).AddRow("Title", "Content"))
+				mock.ExpectExec("UPDATE snippets").WithArgs("Title", "Content", 42).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(tt.insertRevision).WithArgs(1, 42).WillReturnResult(sqlmock.NewResult(3, 1))
+				mock.ExpectCommit()
+
+				assert.NoError(t, repo.Restore(ctx, 42, 1, 1))
+			})
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserBackends(t *testing.T) {
+	for _, tt := range sqlBackends {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("Insert NOK Case - Duplicate Email", func(t *testing.T) {
+				db, mock := NewMock()
+				mock.ExpectExec("INSERT INTO users").WithArgs(
+					"Name", "Email", sqlmock.AnyArg(),
+				).WillReturnError(tt.duplicateEmail)
+
+				err := tt.newUsers(db).Insert("Name", "Email", "Password")
+				assert.Equal(t, models.ErrDuplicateEmail, err)
+			})
+			t.Run("Get NOK Case - No Record", func(t *testing.T) {
+				db, mock := NewMock()
+				mock.ExpectQuery("SELECT (.+) FROM users").WithArgs(1).WillReturnError(sql.ErrNoRows)
+
+				user, err := tt.newUsers(db).Get(1)
+				assert.Nil(t, user)
+				assert.Equal(t, models.ErrNoRecord, err)
+			})
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package test
+
+import (
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"snippetbox/pkg/client"
+	"snippetbox/pkg/models"
+	"snippetbox/pkg/models/memory"
+	"testing"
+)
+
+// Returns a TLS server answering the API from the memory stores, and the
+// PEM file of its self-signed certificate.
+func newTLSAPIServer(t *testing.T, users *memory.UserModel, tokens *memory.TokenModel) (*httptest.Server, string) {
+	t.Helper()
+	ts := httptest.NewTLSServer(newAPIServer(t, memory.NewSnippetModel(), users, tokens).Handler)
+	t.Cleanup(ts.Close)
+
+	caFile := filepath.Join(t.TempDir(), "cert.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caFile, cert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return ts, caFile
+}
+
+func TestClient(t *testing.T) {
+	users := memory.NewUserModel()
+	assert.NoError(t, users.Insert("Name", "name@example.com", "C0mpl3xPass!"))
+	tokens := memory.NewTokenModel()
+	token, err := tokens.Insert(ctx, 1, "CLI", models.ScopeWrite)
+	assert.NoError(t, err)
+	ts, caFile := newTLSAPIServer(t, users, tokens)
+
+	c, err := client.New(ts.URL+"/", token, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Create, Get, List and Delete OK Case", func(t *testing.T) {
+		snippet, err := c.Create(ctx, &client.SnippetRequest{Title: "main.go", Content: "package main", Language: "go", Expires: "7", Tags: []string{"go"}})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, snippet.UserID)
+		assert.Equal(t, ts.URL+"/snippet/1", c.URL(snippet))
+
+		snippet, err = c.Get(ctx, snippet.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "package main", snippet.Content)
+		assert.Equal(t, []string{"go"}, snippet.Tags)
+
+		page, err := c.List(ctx, client.ListOptions{Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, page.Snippets, 1)
+		assert.Empty(t, page.Next)
+
+		assert.NoError(t, c.Delete(ctx, snippet.ID))
+		_, err = c.Get(ctx, snippet.ID)
+		assert.True(t, client.IsStatus(err, http.StatusNotFound), "%v", err)
+	})
+	t.Run("Create NOK Case - Invalid", func(t *testing.T) {
+		_, err := c.Create(ctx, &client.SnippetRequest{Title: "Title", Expires: "7"})
+		assert.True(t, client.IsStatus(err, http.StatusUnprocessableEntity))
+		assert.Contains(t, err.Error(), "content:")
+	})
+	t.Run("Create NOK Case - Revoked token", func(t *testing.T) {
+		other, err := client.New(ts.URL, "sb_unknown", caFile)
+		assert.NoError(t, err)
+		_, err = other.Create(ctx, &client.SnippetRequest{Title: "Title", Content: "Content", Expires: "7"})
+		assert.True(t, client.IsStatus(err, http.StatusUnauthorized))
+	})
+	t.Run("NOK Case - Untrusted certificate", func(t *testing.T) {
+		other, err := client.New(ts.URL, token, "")
+		assert.NoError(t, err)
+		_, err = other.List(ctx, client.ListOptions{})
+		assert.Error(t, err)
+	})
+}
+
+func TestClientConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cli.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"server": "https://example.com", "token": "sb_file", "ca_file": "cert.pem"}`), 0600))
+
+	t.Run("LoadConfig OK Case", func(t *testing.T) {
+		config, err := client.LoadConfig(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com", config.Server)
+		assert.Equal(t, "sb_file", config.Token)
+		assert.Equal(t, filepath.Join(dir, "cert.pem"), config.CAFile)
+	})
+	t.Run("ApplyEnv OK Case - Environment wins", func(t *testing.T) {
+		t.Setenv(client.EnvToken, "sb_env")
+		t.Setenv(client.EnvServer, "")
+		config, err := client.LoadConfig(path)
+		assert.NoError(t, err)
+		config.ApplyEnv()
+		assert.Equal(t, "sb_env", config.Token)
+		assert.Equal(t, "https://example.com", config.Server)
+	})
+	t.Run("ApplyEnv OK Case - Default server", func(t *testing.T) {
+		t.Setenv(client.EnvServer, "")
+		config := &client.Config{}
+		config.ApplyEnv()
+		assert.Equal(t, client.DefaultServer, config.Server)
+	})
+	t.Run("LoadConfig NOK Case - Missing file", func(t *testing.T) {
+		_, err := client.LoadConfig(filepath.Join(dir, "missing.json"))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
@@ -1,9 +1,12 @@
 package test
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"snippetbox/cmd/server"
+	"snippetbox/pkg/models"
 	"snippetbox/pkg/models/mysql"
 	"testing"
 	"time"
@@ -12,6 +15,8 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+var ctx = context.Background()
+
 func NewMock() (*sql.DB, sqlmock.Sqlmock) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -21,14 +26,60 @@ func NewMock() (*sql.DB, sqlmock.Sqlmock) {
 	return db, mock
 }
 
+// The columns of the snippetSelect queries of the SQL backends, in the order
+// scanSnippet() reads them.
+var snippetColumns = []string{"id", "title", "content", "created", "expires", "user_id", "name", "language", "visibility", "slug", "hashed_password", "views_left", "deleted_at", "forked_from"}
+
+// A row of snippetColumns. The fields left to their zero value, and the
+// columns without a field, hold the defaults of snippetRows.
+type snippetRow struct {
+	ID         int
+	Title      string
+	Created    interface{}
+	Expires    interface{}
+	UserID     int
+	Author     string
+	Visibility string
+	Slug       interface{}
+	DeletedAt  interface{}
+	ForkedFrom interface{}
+}
+
+// Returns the rows a snippetSelect query answers with, filling in the
+// defaults.
+func snippetRows(rows ...snippetRow) *sqlmock.Rows {
+	result := sqlmock.NewRows(snippetColumns)
+	for _, row := range rows {
+		if row.Title == "" {
+			row.Title = "Title"
+		}
+		if row.Created == nil {
+			row.Created = time.Now()
+		}
+		if row.Expires == nil {
+			row.Expires = "2024-01-24T10:23:42Z"
+		}
+		if row.UserID == 0 {
+			row.UserID = 1
+		}
+		if row.Author == "" {
+			row.Author = "Name"
+		}
+		if row.Visibility == "" {
+			row.Visibility = "public"
+		}
+		result.AddRow(row.ID, row.Title, "Content", row.Created, row.Expires, row.UserID, row.Author, "plaintext",
+			row.Visibility, row.Slug, nil, nil, row.DeletedAt, row.ForkedFrom)
+	}
+	return result
+}
+
 func TestInsert(t *testing.T) {
 	db, mock := NewMock()
 	infoLog, errorLog := server.CreateLoggers()
 
 	// New mocks due to NewSnippetModel() factory
-	mock.ExpectBegin()
-	_ = mock.ExpectPrepare("SELECT ...") // SELECT for Latest Statement
-	query := "INSERT INTO snippets \\(title, content, created, expires\\) VALUES\\(\\?, \\?, UTC_TIMESTAMP\\(\\), DATE_ADD\\(UTC_TIMESTAMP\\(\\), INTERVAL \\? DAY\\)\\)"
+	query := "INSERT INTO snippets \\(user_id, title, content, language, visibility, slug, hashed_password, views_left, created, expires\\) VALUES\\(\\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, UTC_TIMESTAMP\\(\\), \\?\\)"
 	prep := mock.ExpectPrepare(query)
 	_ = mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items
 
@@ -44,21 +95,31 @@ func TestInsert(t *testing.T) {
 		return
 	}
 	t.Run("Insert OK Case", func(t *testing.T) {
+		mock.ExpectBegin()
 		prep.ExpectExec().WithArgs(
+			1,
 			"Title",
 			"Content",
-			"1").WillReturnResult(sqlmock.NewResult(0, 1))
-
-		_, err := repo.Insert("Title", "Content", "1")
+			"",
+			models.Public,
+			nil,
+			nil,
+			nil,
+			sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO snippet_revisions").WithArgs(1, 0).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		_, err := repo.Insert(ctx, 1, "Title", "Content", "", models.Public, "", days(1), 0)
 		assert.NoError(t, err)
 	})
 	t.Run("Insert NOK Case", func(t *testing.T) {
-		query := "INSERT INTO snippets \\(title, content, created, expires\\) VALUES\\(\\?, \\?, UTC_TIMESTAMP\\(\\), DATE_ADD\\(UTC_TIMESTAMP\\(\\), INTERVAL \\? DAY\\)\\)"
+		query := "INSERT INTO snippets \\(user_id, title, content, language, visibility, slug, hashed_password, views_left, created, expires\\) VALUES\\(\\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, UTC_TIMESTAMP\\(\\), \\?\\)"
 		mock.ExpectQuery(query).WithArgs(
+			1,
 			"Title",
 			"Content",
-			"1").WillReturnError(err)
-		_, err := repo.Insert("Title", "Content", "1")
+			sqlmock.AnyArg()).WillReturnError(err)
+		_, err := repo.Insert(ctx, 1, "Title", "Content", "", models.Public, "", days(1), 0)
 		assert.Error(t, err)
 	})
 }
@@ -68,11 +129,9 @@ func TestGet(t *testing.T) {
 	infoLog, errorLog := server.CreateLoggers()
 
 	// New mocks due to NewSnippetModel() factory
-	mock.ExpectBegin()
-	_ = mock.ExpectPrepare("SELECT ...") // SELECT for Latest Statement
 	_ = mock.ExpectPrepare("INSERT ...")
 
-	query := "SELECT id, title, content, created, expires FROM snippets WHERE expires \\> UTC_TIMESTAMP\\(\\) AND id \\= \\?"
+	query := "SELECT s.id, s.title, s.content, s.created, s.expires, s.user_id, u.name, s.language, s.visibility, s.slug, s.hashed_password, s.views_left, s.deleted_at, s.forked_from FROM snippets s LEFT JOIN users u ON u.id \\= s.user_id WHERE s.expires \\> UTC_TIMESTAMP\\(\\) AND s.deleted_at IS NULL AND s.id \\= \\?"
 	prep := mock.ExpectPrepare(query) // SELECT for just one of the items
 
 	repo, err := mysql.NewSnippetModel(db, infoLog, errorLog)
@@ -88,39 +147,31 @@ func TestGet(t *testing.T) {
 	}
 
 	t.Run("Get() OK Case", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "title", "content", "created", "expires"})
-		rows.AddRow(0, "Title", "Content", time.Now(), "2024-01-24T10:23:42Z")
+		rows := snippetRows(snippetRow{})
 		prep.ExpectQuery().WithArgs(0).WillReturnRows(rows)
+		mock.ExpectQuery("SELECT t.name FROM tags").WithArgs(0).WillReturnRows(sqlmock.NewRows([]string{"name"}))
 
-		output, err := repo.Get(0)
+		output, err := repo.Get(ctx, 0)
 		assert.NotNil(t, output)
 		assert.NoError(t, err)
 	})
 	t.Run("Get() NOK Case", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "title", "content", "created", "expires"})
-		rows.AddRow(0, "Title", "Content", time.Now(), "2024-01-24T10:23:42Z")
-
 		wrongId := 2
-		output, err := repo.Get(wrongId)
+		output, err := repo.Get(ctx, wrongId)
 		assert.Nil(t, output)
 		prep.ExpectQuery().WithArgs().WillReturnError(err)
 		assert.Error(t, err)
 	})
 }
 
-func TestLatest(t *testing.T) {
-	t.Run("Latest() OK Case", func(t *testing.T) {
+func TestList(t *testing.T) {
+	t.Run("List() OK Case", func(t *testing.T) {
 		db, mock := NewMock()
 		infoLog, errorLog := server.CreateLoggers()
 
 		// New mocks due to NewSnippetModel() factory
-		mock.ExpectBegin()
-
-		// SELECT for Latest Statement
-		query := "SELECT id, title, content, created, expires FROM snippets WHERE expires \\> UTC_TIMESTAMP\\(\\) ORDER BY created DESC LIMIT 10"
-		prep := mock.ExpectPrepare(query)
 		_ = mock.ExpectPrepare("INSERT ...")
-		_ = mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items		repo, err := mysql.NewSnippetModel(db, infoLog, errorLog)
+		_ = mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items
 
 		repo, err := mysql.NewSnippetModel(db, infoLog, errorLog)
 		defer func() {
@@ -129,37 +180,27 @@ func TestLatest(t *testing.T) {
 			}
 		}()
 
-		defer func() {
-			if err == nil {
-				repo.Close()
-			}
-		}()
-
 		if err != nil {
 			log.Printf("Creating NewSnippetModel failed")
 			return
 		}
 
-		rows := sqlmock.NewRows([]string{"id", "title", "content", "created", "expires"})
-		rows.AddRow(0, "Title", "Content", time.Now(), "2024-01-24T10:23:42Z")
-		prep.ExpectQuery().WillReturnRows(rows)
+		query := "SELECT s.id, s.title, s.content, s.created, s.expires, s.user_id, u.name, s.language, s.visibility, s.slug, s.hashed_password, s.views_left, s.deleted_at, s.forked_from FROM snippets s LEFT JOIN users u ON u.id \\= s.user_id WHERE s.expires \\> UTC_TIMESTAMP\\(\\) AND s.deleted_at IS NULL AND s.visibility \\= 'public' ORDER BY s.created DESC, s.id DESC LIMIT \\?"
+		rows := snippetRows(snippetRow{})
+		mock.ExpectQuery(query).WithArgs(models.DefaultPageSize + 1).WillReturnRows(rows)
 
-		output, err := repo.Latest()
-		assert.NotNil(t, output)
+		output, err := repo.List(ctx, models.ListOptions{})
 		assert.NoError(t, err)
+		assert.Len(t, output.Snippets, 1)
+		assert.Nil(t, output.Next)
 	})
-	t.Run("Latest() NOK Case - No Records found", func(t *testing.T) {
+	t.Run("List() NOK Case - Query fails", func(t *testing.T) {
 		db, mock := NewMock()
 		infoLog, errorLog := server.CreateLoggers()
 
 		// New mocks due to NewSnippetModel() factory
-		mock.ExpectBegin()
-
-		// SELECT for Latest Statement
-		query := "SELECT id, title, content, created, expires FROM snippets WHERE expires \\> UTC_TIMESTAMP\\(\\) ORDER BY created DESC LIMIT 10"
-		prep := mock.ExpectPrepare(query)
 		_ = mock.ExpectPrepare("INSERT ...")
-		_ = mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items		repo, err := mysql.NewSnippetModel(db, infoLog, errorLog)
+		_ = mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items
 
 		repo, err := mysql.NewSnippetModel(db, infoLog, errorLog)
 		defer func() {
@@ -172,22 +213,17 @@ func TestLatest(t *testing.T) {
 			log.Printf("Creating NewSnippetModel failed")
 			return
 		}
-		prep.ExpectQuery().WillReturnError(err)
-		output, err := repo.Latest()
+		mock.ExpectQuery("SELECT (.+) FROM snippets").WillReturnError(sql.ErrConnDone)
+		output, err := repo.List(ctx, models.ListOptions{})
 		assert.Nil(t, output)
 		assert.Error(t, err)
 	})
-	t.Run("Latest() NOK Case - No Prepared Query exists", func(t *testing.T) {
+	t.Run("Insert() NOK Case - No Prepared Query exists", func(t *testing.T) {
 		db, mock := NewMock()
 		infoLog, errorLog := server.CreateLoggers()
 
 		// New mocks due to NewSnippetModel() factory
-		mock.ExpectBegin()
-
-		// SELECT for Latest Statement
-		query := "SELECT id, title, content, created, expires FROM snippets WHERE expires \\> UTC_TIMESTAMP\\(\\) ORDER BY created DESC LIMIT 10"
-		prep := mock.ExpectPrepare(query)
-		_ = mock.ExpectPrepare("INSERT ...")
+		prep := mock.ExpectPrepare("INSERT ...")
 		_ = mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items
 
 		repo, err := mysql.NewSnippetModel(db, infoLog, errorLog)
@@ -201,23 +237,18 @@ func TestLatest(t *testing.T) {
 			log.Printf("Creating NewSnippetModel failed")
 			return
 		}
-		repo.LatestStatement = nil
-		output, err := repo.Latest()
+		repo.InsertStatement = nil
+		output, err := repo.Insert(ctx, 1, "Title", "Content", "", models.Public, "", days(1), 0)
 		prep.ExpectQuery().WillReturnError(err)
-		assert.Nil(t, output)
+		assert.EqualValues(t, -1, output)
 		assert.Error(t, err)
 	})
-	t.Run("Insert() NOK Case - No Prepared Query exists", func(t *testing.T) {
+	t.Run("Get() NOK Case - No Prepared Query exists", func(t *testing.T) {
 		db, mock := NewMock()
 		infoLog, errorLog := server.CreateLoggers()
 
 		// New mocks due to NewSnippetModel() factory
-		mock.ExpectBegin()
-
-		// SELECT for Latest Statement
-		query := "SELECT id, title, content, created, expires FROM snippets WHERE expires \\> UTC_TIMESTAMP\\(\\) ORDER BY created DESC LIMIT 10"
-		prep := mock.ExpectPrepare(query)
-		_ = mock.ExpectPrepare("INSERT ...")
+		prep := mock.ExpectPrepare("INSERT ...")
 		_ = mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items
 
 		repo, err := mysql.NewSnippetModel(db, infoLog, errorLog)
@@ -231,40 +262,63 @@ func TestLatest(t *testing.T) {
 			log.Printf("Creating NewSnippetModel failed")
 			return
 		}
-		repo.InsertStatement = nil
-		output, err := repo.Insert("Title", "Content", "1")
+		repo.GetStatement = nil
+		output, err := repo.Get(ctx, 1)
 		prep.ExpectQuery().WillReturnError(err)
-		assert.EqualValues(t, -1, output)
+		assert.Nil(t, output)
 		assert.Error(t, err)
 	})
-	t.Run("Get() NOK Case - No Prepared Query exists", func(t *testing.T) {
-		db, mock := NewMock()
-		infoLog, errorLog := server.CreateLoggers()
+}
 
-		// New mocks due to NewSnippetModel() factory
+func TestWithTx(t *testing.T) {
+	db, mock := NewMock()
+	infoLog, errorLog := server.CreateLoggers()
+
+	// New mocks due to NewSnippetModel() factory
+	_ = mock.ExpectPrepare("INSERT ...")
+	_ = mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items
+
+	repo, err := mysql.NewSnippetModel(db, infoLog, errorLog)
+	defer func() {
+		if err == nil {
+			repo.Close()
+		}
+	}()
+
+	if err != nil {
+		log.Printf("Creating NewSnippetModel failed")
+		return
+	}
+
+	t.Run("WithTx() OK Case - Commit", func(t *testing.T) {
 		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE snippets ...").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
 
-		// SELECT for Latest Statement
-		query := "SELECT id, title, content, created, expires FROM snippets WHERE expires \\> UTC_TIMESTAMP\\(\\) ORDER BY created DESC LIMIT 10"
-		prep := mock.ExpectPrepare(query)
-		_ = mock.ExpectPrepare("INSERT ...")
-		_ = mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items
+		err := repo.WithTx(ctx, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "UPDATE snippets SET title = ? WHERE id = ?", "Title", 1)
+			return err
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("WithTx() NOK Case - Rollback", func(t *testing.T) {
+		txErr := errors.New("something went wrong")
+		mock.ExpectBegin()
+		mock.ExpectRollback()
 
-		repo, err := mysql.NewSnippetModel(db, infoLog, errorLog)
-		defer func() {
-			if err == nil {
-				repo.Close()
-			}
-		}()
+		err := repo.WithTx(ctx, func(tx *sql.Tx) error {
+			return txErr
+		})
+		assert.Equal(t, txErr, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("WithTx() NOK Case - Begin fails", func(t *testing.T) {
+		mock.ExpectBegin().WillReturnError(sql.ErrConnDone)
 
-		if err != nil {
-			log.Printf("Creating NewSnippetModel failed")
-			return
-		}
-		repo.LatestStatement = nil
-		output, err := repo.Get(1)
-		prep.ExpectQuery().WillReturnError(err)
-		assert.Nil(t, output)
+		err := repo.WithTx(ctx, func(tx *sql.Tx) error {
+			return nil
+		})
 		assert.Error(t, err)
 	})
 }
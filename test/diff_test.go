@@ -0,0 +1,75 @@
+package test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"snippetbox/pkg/diff"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		testName string
+		a, b     []string
+		want     string
+	}{
+		{
+			testName: "Equal",
+			a:        []string{"a", "b"},
+			b:        []string{"a", "b"},
+			want:     " a| b",
+		},
+		{
+			testName: "Everything inserted",
+			a:        nil,
+			b:        []string{"a", "b"},
+			want:     "+a|+b",
+		},
+		{
+			testName: "Everything deleted",
+			a:        []string{"a", "b"},
+			b:        nil,
+			want:     "-a|-b",
+		},
+		{
+			testName: "Line changed in the middle",
+			a:        []string{"a", "b", "c"},
+			b:        []string{"a", "x", "c"},
+			want:     " a|-b|+x| c",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got := []string{}
+			for _, l := range diff.Lines(tt.a, tt.b) {
+				got = append(got, l.String())
+			}
+			assert.Equal(t, tt.want, strings.Join(got, "|"))
+		})
+	}
+}
+
+func TestDiffUnified(t *testing.T) {
+	t.Run("Equal texts have no hunks", func(t *testing.T) {
+		assert.Empty(t, diff.Unified("a\nb\n", "a\r\nb", 3))
+	})
+	t.Run("Far apart changes get their own hunks", func(t *testing.T) {
+		old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+		new := "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n"
+		hunks := diff.Unified(old, new, 2)
+		assert.Len(t, hunks, 2)
+		assert.Equal(t, "@@ -1,3 +1,3 @@", hunks[0].Header())
+		assert.Equal(t, "@@ -8,3 +8,3 @@", hunks[1].Header())
+	})
+	t.Run("Close changes share a hunk", func(t *testing.T) {
+		hunks := diff.Unified("1\n2\n3\n4\n5\n", "one\n2\n3\n4\nfive\n", 2)
+		assert.Len(t, hunks, 1)
+		assert.Equal(t, "@@ -1,5 +1,5 @@", hunks[0].Header())
+	})
+	t.Run("Insert into an empty text", func(t *testing.T) {
+		hunks := diff.Unified("", "a\n", 3)
+		assert.Len(t, hunks, 1)
+		assert.Equal(t, "@@ -0,0 +1,1 @@", hunks[0].Header())
+	})
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"snippetbox/pkg/forms"
+	"testing"
+	"time"
+)
+
+func TestFormTags(t *testing.T) {
+	t.Run("Tags OK Case - Normalised", func(t *testing.T) {
+		assert.Equal(t, []string{"go", "web", "c++"}, forms.Tags(" Go,web  GO, c++ ,"))
+		assert.Empty(t, forms.Tags(""))
+	})
+
+	tests := []struct {
+		name  string
+		tags  string
+		valid bool
+	}{
+		{"Valid", "go, node.js c++", true},
+		{"Empty", "", true},
+		{"Too many", "a b c d e", false},
+		{"Too long", "abcdefghijk", false},
+		{"Invalid characters", "<b>", false},
+		{"Leading separator", "-go", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := forms.New(url.Values{"tags": {tt.tags}})
+			form.ValidTags("tags", 4, 10)
+			assert.Equal(t, tt.valid, form.Valid())
+		})
+	}
+}
+
+func TestFormDurations(t *testing.T) {
+	t.Run("ParseDuration OK Case", func(t *testing.T) {
+		for value, want := range map[string]time.Duration{
+			"90m":   90 * time.Minute,
+			"1h30m": 90 * time.Minute,
+			"3d":    72 * time.Hour,
+			"2w":    14 * 24 * time.Hour,
+			"1y":    365 * 24 * time.Hour,
+		} {
+			d, err := forms.ParseDuration(value)
+			assert.NoError(t, err, value)
+			assert.Equal(t, want, d, value)
+		}
+	})
+	t.Run("ParseDuration NOK Case", func(t *testing.T) {
+		for _, value := range []string{"", "d", "1.5d", "-1w", "soon", "999999y"} {
+			_, err := forms.ParseDuration(value)
+			assert.Error(t, err, value)
+		}
+	})
+	t.Run("FormatDuration OK Case", func(t *testing.T) {
+		assert.Equal(t, "30d", forms.FormatDuration(30*24*time.Hour))
+		assert.Equal(t, "1h30m", forms.FormatDuration(90*time.Minute))
+		assert.Equal(t, "5m", forms.FormatDuration(5*time.Minute))
+		assert.Equal(t, "12h", forms.FormatDuration(12*time.Hour))
+	})
+
+	tests := []struct {
+		name  string
+		value string
+		max   time.Duration
+		valid bool
+	}{
+		{"Within bounds", "2d", 7 * 24 * time.Hour, true},
+		{"Empty", "", time.Hour, true},
+		{"Too short", "1m", time.Hour, false},
+		{"Too long", "2w", 7 * 24 * time.Hour, false},
+		{"Unbounded", "100y", 0, true},
+		{"Invalid", "tomorrow", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run("DurationBetween "+tt.name, func(t *testing.T) {
+			form := forms.New(url.Values{"expires_in": {tt.value}})
+			form.DurationBetween("expires_in", 5*time.Minute, tt.max)
+			assert.Equal(t, tt.valid, form.Valid())
+		})
+	}
+}
+
+func TestFormDatetimes(t *testing.T) {
+	min := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	max := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		max   time.Time
+		valid bool
+	}{
+		{"Within bounds", "2024-06-01T12:30", max, true},
+		{"Empty", "", max, true},
+		{"Too early", "2023-12-31T23:59", max, false},
+		{"Too late", "2025-01-01T00:01", max, false},
+		{"Unbounded", "9000-01-01T00:00", time.Time{}, true},
+		{"Invalid", "next week", max, false},
+	}
+	for _, tt := range tests {
+		t.Run("DatetimeBetween "+tt.name, func(t *testing.T) {
+			form := forms.New(url.Values{"expires_at": {tt.value}})
+			form.DatetimeBetween("expires_at", min, tt.max)
+			assert.Equal(t, tt.valid, form.Valid())
+		})
+	}
+}
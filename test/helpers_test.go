@@ -1,7 +1,6 @@
 package test
 
 import (
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/golangcollege/sessions"
 	"log"
 	"net/http"
@@ -15,8 +14,6 @@ import (
 func TestHelpers(t *testing.T) {
 	db, mock := NewMock()
 	// New mocks due to NewSnippetModel() factory
-	mock.ExpectBegin()
-	_ = mock.ExpectPrepare("SELECT ...") // SELECT for Latest Statement
 	_ = mock.ExpectPrepare("INSERT ...")
 	prep := mock.ExpectPrepare("SELECT ...") // SELECT for just one of the items
 
@@ -55,8 +52,7 @@ func TestHelpers(t *testing.T) {
 		}
 
 		// Adding ExpectPrepare to DB Expectations
-		rows := sqlmock.NewRows([]string{"id", "title", "content", "created", "expires"})
-		rows.AddRow(0, "Title", "Content", time.Now(), "2024-01-24T10:23:42Z")
+		rows := snippetRows(snippetRow{})
 		prep.ExpectQuery().WillReturnRows(rows)
 
 		request := newRequest(http.MethodGet, "")
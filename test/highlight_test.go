@@ -0,0 +1,62 @@
+package test
+
+import (
+	"snippetbox/pkg/highlight"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlightDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"Go", "package main\n\nfunc main() {\n\tx := 1\n}", "go"},
+		{"Python", "import os\n\ndef main():\n    print(os.getcwd())", "python"},
+		{"JavaScript", "const add = (a, b) => a + b;\nconsole.log(add(1, 2));", "javascript"},
+		{"Java", "public class Main {\n  public static void main(String[] args) {\n    System.out.println(1);\n  }\n}", "java"},
+		{"C", "#include <stdio.h>\n\nint main() {\n  printf(\"hi\");\n}", "c"},
+		{"Rust", "use std::io;\n\nfn main() {\n    let mut x = 1;\n    println!(\"{}\", x);\n}", "rust"},
+		{"SQL", "select id, title\nfrom snippets\nwhere id = 1;", "sql"},
+		{"Shell", "#!/bin/bash\necho \"${HOME}\"", "shell"},
+		{"Plain text", "Just some notes.", highlight.Plain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, highlight.Detect(tt.code))
+		})
+	}
+}
+
+func TestHighlightTokenize(t *testing.T) {
+	t.Run("Tokens cover the code", func(t *testing.T) {
+		code := "func f() string {\n\t// say \"hi\"\n\treturn `a\nb` + \"c\\\"d\" /* x */ + 42\n}"
+		tokens := highlight.Lookup("go").Tokenize(code)
+
+		var b strings.Builder
+		kinds := map[string]highlight.Kind{}
+		for _, token := range tokens {
+			b.WriteString(token.Text)
+			kinds[token.Text] = token.Kind
+		}
+		assert.Equal(t, code, b.String())
+		assert.Equal(t, highlight.Keyword, kinds["func"])
+		assert.Equal(t, highlight.Keyword, kinds["return"])
+		assert.Equal(t, highlight.Comment, kinds[`// say "hi"`])
+		assert.Equal(t, highlight.Comment, kinds["/* x */"])
+		assert.Equal(t, highlight.String, kinds["`a\nb`"])
+		assert.Equal(t, highlight.String, kinds[`"c\"d"`])
+		assert.Equal(t, highlight.Number, kinds["42"])
+	})
+	t.Run("SQL keywords ignore case", func(t *testing.T) {
+		tokens := highlight.Lookup("sql").Tokenize("SELECT x")
+		assert.Equal(t, highlight.Token{Kind: highlight.Keyword, Text: "SELECT"}, tokens[0])
+	})
+	t.Run("Unknown languages are plain text", func(t *testing.T) {
+		tokens := highlight.Lookup("cobol").Tokenize("func 42")
+		assert.Equal(t, []highlight.Token{{Kind: highlight.Text, Text: "func 42"}}, tokens)
+	})
+}
@@ -0,0 +1,805 @@
+package test
+
+import (
+	"fmt"
+	"github.com/golangcollege/sessions"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"snippetbox/cmd/server"
+	"snippetbox/pkg/models"
+	"snippetbox/pkg/models/memory"
+	"testing"
+	"time"
+)
+
+func TestMemorySnippetModel(t *testing.T) {
+	t.Run("Insert and Get OK Case", func(t *testing.T) {
+		repo := memory.NewSnippetModel()
+		id, err := repo.Insert(ctx, 1, "Title", "Content", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+
+		snippet, err := repo.Get(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, "Title", snippet.Title)
+		assert.Equal(t, "Content", snippet.Content)
+	})
+	t.Run("Insert OK Case - Never expires", func(t *testing.T) {
+		repo := memory.NewSnippetModel()
+		id, err := repo.Insert(ctx, 1, "Title", "Content", "", models.Public, "", models.Never, 0)
+		assert.NoError(t, err)
+
+		snippet, err := repo.Get(ctx, id)
+		assert.NoError(t, err)
+		assert.True(t, snippet.NeverExpires())
+	})
+	t.Run("Get NOK Case - No Record", func(t *testing.T) {
+		repo := memory.NewSnippetModel()
+		snippet, err := repo.Get(ctx, 1)
+		assert.Nil(t, snippet)
+		assert.Equal(t, models.ErrNoRecord, err)
+	})
+	t.Run("Get NOK Case - Expired", func(t *testing.T) {
+		repo := memory.NewSnippetModel()
+		id, err := repo.Insert(ctx, 1, "Title", "Content", "", models.Public, "", days(0), 0)
+		assert.NoError(t, err)
+
+		snippet, err := repo.Get(ctx, id)
+		assert.Nil(t, snippet)
+		assert.Equal(t, models.ErrNoRecord, err)
+	})
+	t.Run("ByUser OK Case - Expired included, author filled in", func(t *testing.T) {
+		users := memory.NewUserModel()
+		assert.NoError(t, users.Insert("Name", "name@example.com", "C0mpl3xPass!"))
+		repo := memory.NewSnippetModel()
+		repo.Users = users
+
+		_, err := repo.Insert(ctx, 1, "Expired", "Content", "", models.Public, "", days(0), 0)
+		assert.NoError(t, err)
+		_, err = repo.Insert(ctx, 2, "Someone else", "Content", "", models.Public, "", days(1), 0)
+		assert.NoError(t, err)
+
+		snippets, err := repo.ByUser(ctx, 1)
+		assert.NoError(t, err)
+		assert.Len(t, snippets, 1)
+		assert.Equal(t, "Name", snippets[0].Author)
+		assert.True(t, snippets[0].Expired())
+	})
+	t.Run("List OK Case - Newest first, expired skipped, paged", func(t *testing.T) {
+		repo := memory.NewSnippetModel()
+		_, err := repo.Insert(ctx, 1, "Expired", "Content", "", models.Public, "", days(0), 0)
+		assert.NoError(t, err)
+		for i := 0; i < 12; i++ {
+			_, err := repo.Insert(ctx, 1, "Title", "Content", "", models.Public, "", days(1), 0)
+			assert.NoError(t, err)
+		}
+
+		page, err := repo.List(ctx, models.ListOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, page.Snippets, 10)
+		assert.Equal(t, 13, page.Snippets[0].ID)
+		for _, s := range page.Snippets {
+			assert.NotEqual(t, "Expired", s.Title)
+		}
+
+		page, err = repo.List(ctx, models.ListOptions{After: page.Next})
+		assert.NoError(t, err)
+		assert.Len(t, page.Snippets, 2)
+		assert.Equal(t, 3, page.Snippets[0].ID)
+		assert.Nil(t, page.Next)
+	})
+	t.Run("List OK Case - Filters", func(t *testing.T) {
+		repo := memory.NewSnippetModel()
+		_, err := repo.Insert(ctx, 1, "Soon", "Content", "", models.Public, "", days(1), 0)
+		assert.NoError(t, err)
+		_, err = repo.Insert(ctx, 2, "Later", "Content", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+
+		page, err := repo.List(ctx, models.ListOptions{UserID: 2})
+		assert.NoError(t, err)
+		assert.Len(t, page.Snippets, 1)
+		assert.Equal(t, "Later", page.Snippets[0].Title)
+
+		page, err = repo.List(ctx, models.ListOptions{ExpiresBefore: time.Now().Add(48 * time.Hour)})
+		assert.NoError(t, err)
+		assert.Len(t, page.Snippets, 1)
+		assert.Equal(t, "Soon", page.Snippets[0].Title)
+
+		page, err = repo.List(ctx, models.ListOptions{CreatedBefore: time.Now().Add(-time.Hour)})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Snippets)
+	})
+	t.Run("Search OK Case - Every word, any case, title or content", func(t *testing.T) {
+		repo := memory.NewSnippetModel()
+		_, err := repo.Insert(ctx, 1, "Go channels", "Buffered and unbuffered", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+		_, err = repo.Insert(ctx, 1, "Go maps", "Unordered", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+
+		page, err := repo.Search(ctx, "go BUFFERED", models.ListOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, page.Snippets, 1)
+		assert.Equal(t, "Go channels", page.Snippets[0].Title)
+
+		page, err = repo.Search(ctx, "   ", models.ListOptions{})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Snippets)
+	})
+	t.Run("Tags OK Case - Listed, filtered and suggested", func(t *testing.T) {
+		repo := memory.NewSnippetModel()
+		first, err := repo.Insert(ctx, 1, "First", "Content", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+		second, err := repo.Insert(ctx, 1, "Second", "Content", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+		expired, err := repo.Insert(ctx, 1, "Expired", "Content", "", models.Public, "", days(0), 0)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.SetTags(ctx, first, []string{"web", "go"}))
+		assert.NoError(t, repo.SetTags(ctx, second, []string{"go", "gin"}))
+		assert.NoError(t, repo.SetTags(ctx, expired, []string{"gorm"}))
+		assert.Equal(t, models.ErrNoRecord, repo.SetTags(ctx, 100, []string{"go"}))
+
+		snippet, err := repo.Get(ctx, first)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"go", "web"}, snippet.Tags)
+
+		page, err := repo.List(ctx, models.ListOptions{Tag: "web"})
+		assert.NoError(t, err)
+		assert.Len(t, page.Snippets, 1)
+		assert.Equal(t, "First", page.Snippets[0].Title)
+
+		tags, err := repo.SuggestTags(ctx, "g", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"go", "gin"}, tags)
+		tags, err = repo.SuggestTags(ctx, "g", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"go"}, tags)
+	})
+	t.Run("Update and Delete OK Case", func(t *testing.T) {
+		repo := memory.NewSnippetModel()
+		id, err := repo.Insert(ctx, 1, "Title", "Content", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+
+		assert.NoError(t, repo.Update(ctx, id, 1, "New", "Changed", "", models.Public, days(1)))
+		snippet, err := repo.Get(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, "New", snippet.Title)
+		assert.Equal(t, 1, snippet.UserID)
+
+		assert.NoError(t, repo.Delete(ctx, id))
+		_, err = repo.Get(ctx, id)
+		assert.Equal(t, models.ErrNoRecord, err)
+	})
+	t.Run("Update and Delete NOK Case - No Record", func(t *testing.T) {
+		repo := memory.NewSnippetModel()
+		assert.Equal(t, models.ErrNoRecord, repo.Update(ctx, 1, 1, "Title", "Content", "", models.Public, days(7)))
+		assert.Equal(t, models.ErrNoRecord, repo.Delete(ctx, 1))
+	})
+	t.Run("Trash OK Case - Deleted snippets don't leak", func(t *testing.T) {
+		repo := memory.NewSnippetModel()
+		id, err := repo.Insert(ctx, 1, "Go trash", "Content", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.SetTags(ctx, id, []string{"trash"}))
+		assert.NoError(t, repo.Delete(ctx, id))
+
+		_, err = repo.Get(ctx, id)
+		assert.Equal(t, models.ErrNoRecord, err)
+		page, err := repo.List(ctx, models.ListOptions{})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Snippets)
+		page, err = repo.Search(ctx, "go", models.ListOptions{})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Snippets)
+		tags, err := repo.SuggestTags(ctx, "tr", 5)
+		assert.NoError(t, err)
+		assert.Empty(t, tags)
+
+		trash, err := repo.Trash(ctx, 1)
+		assert.NoError(t, err)
+		assert.Len(t, trash, 1)
+		trash, err = repo.Trash(ctx, 2)
+		assert.NoError(t, err)
+		assert.Empty(t, trash)
+
+		assert.NoError(t, repo.Undelete(ctx, id))
+		_, err = repo.Get(ctx, id)
+		assert.NoError(t, err)
+
+		assert.Equal(t, models.ErrNoRecord, repo.Purge(ctx, id))
+		assert.NoError(t, repo.Delete(ctx, id))
+		assert.NoError(t, repo.Purge(ctx, id))
+		assert.Equal(t, models.ErrNoRecord, repo.Undelete(ctx, id))
+	})
+	t.Run("Fork OK Case", func(t *testing.T) {
+		repo := memory.NewSnippetModel()
+		original, err := repo.Insert(ctx, 1, "Title", "Content", "", models.Unlisted, "secret", days(7), 0)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.SetTags(ctx, original, []string{"go"}))
+
+		fork, err := repo.Fork(ctx, original, 2)
+		assert.NoError(t, err)
+		snippet, err := repo.Get(ctx, fork)
+		assert.NoError(t, err)
+		assert.Equal(t, original, snippet.ForkedFrom)
+		assert.Equal(t, 2, snippet.UserID)
+		assert.Equal(t, []string{"go"}, snippet.Tags)
+		assert.False(t, snippet.Protected())
+		assert.NotEmpty(t, snippet.Slug)
+
+		// Only the public forks are listed.
+		forks, err := repo.Forks(ctx, original)
+		assert.NoError(t, err)
+		assert.Empty(t, forks)
+		assert.NoError(t, repo.Update(ctx, fork, 2, "Title", "Content", "", models.Public, days(7)))
+		forks, err = repo.Forks(ctx, original)
+		assert.NoError(t, err)
+		assert.Len(t, forks, 1)
+
+		assert.NoError(t, repo.Delete(ctx, original))
+		_, err = repo.Fork(ctx, original, 2)
+		assert.Equal(t, models.ErrNoRecord, err)
+		assert.NoError(t, repo.Purge(ctx, original))
+		snippet, err = repo.Get(ctx, fork)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, snippet.ForkedFrom)
+	})
+	t.Run("Revisions OK Case - Restore adds a revision", func(t *testing.T) {
+		repo := memory.NewSnippetModel()
+		id, err := repo.Insert(ctx, 1, "First", "one", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Update(ctx, id, 2, "Second", "two", "", models.Public, days(7)))
+
+		revisions, err := repo.Revisions(ctx, id)
+		assert.NoError(t, err)
+		assert.Len(t, revisions, 2)
+		assert.Equal(t, 2, revisions[0].UserID)
+
+		assert.NoError(t, repo.Restore(ctx, id, revisions[1].ID, 1))
+		snippet, err := repo.Get(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, "one", snippet.Content)
+
+		_, err = repo.Revision(ctx, id, 100)
+		assert.Equal(t, models.ErrNoRecord, err)
+	})
+	t.Run("View OK Case - Burned after the last view", func(t *testing.T) {
+		repo := memory.NewSnippetModel()
+		id, err := repo.Insert(ctx, 1, "Title", "Content", "", models.Public, "", days(7), 1)
+		assert.NoError(t, err)
+
+		snippet, err := repo.View(ctx, id)
+		assert.NoError(t, err)
+		assert.True(t, snippet.Burned)
+		_, err = repo.View(ctx, id)
+		assert.Equal(t, models.ErrNoRecord, err)
+
+		burned, err := repo.Burned(ctx, id, "")
+		assert.NoError(t, err)
+		assert.True(t, burned)
+		burned, err = repo.Burned(ctx, id+1, "")
+		assert.NoError(t, err)
+		assert.False(t, burned)
+	})
+}
+
+func TestMemoryUserModel(t *testing.T) {
+	repo := memory.NewUserModel()
+	err := repo.Insert("Name", "name@example.com", "C0mpl3xPass!")
+	assert.NoError(t, err)
+
+	t.Run("Insert NOK Case - Duplicate Email", func(t *testing.T) {
+		err := repo.Insert("Other", "name@example.com", "An0therPass!")
+		assert.Equal(t, models.ErrDuplicateEmail, err)
+	})
+	t.Run("Authenticate OK Case", func(t *testing.T) {
+		id, err := repo.Authenticate("name@example.com", "C0mpl3xPass!")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, id)
+	})
+	t.Run("Authenticate NOK Case - Wrong Password", func(t *testing.T) {
+		_, err := repo.Authenticate("name@example.com", "wrong")
+		assert.Equal(t, models.ErrInvalidCredentials, err)
+	})
+	t.Run("Authenticate NOK Case - Unknown Email", func(t *testing.T) {
+		_, err := repo.Authenticate("nobody@example.com", "C0mpl3xPass!")
+		assert.Equal(t, models.ErrInvalidCredentials, err)
+	})
+	t.Run("Get OK Case", func(t *testing.T) {
+		user, err := repo.Get(1)
+		assert.NoError(t, err)
+		assert.Equal(t, "Name", user.Name)
+		assert.Nil(t, user.HashedPassword)
+	})
+	t.Run("Get NOK Case - No Record", func(t *testing.T) {
+		user, err := repo.Get(2)
+		assert.Nil(t, user)
+		assert.Equal(t, models.ErrNoRecord, err)
+	})
+}
+
+func TestMemoryTokenModel(t *testing.T) {
+	repo := memory.NewTokenModel()
+	token, err := repo.Insert(ctx, 1, "Script", models.ScopeWrite)
+	assert.NoError(t, err)
+
+	t.Run("Authenticate OK Case", func(t *testing.T) {
+		tok, err := repo.Authenticate(ctx, token)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, tok.UserID)
+		assert.Equal(t, models.ScopeWrite, tok.Scope)
+	})
+	t.Run("Authenticate NOK Case - Unknown Token", func(t *testing.T) {
+		_, err := repo.Authenticate(ctx, "sb_unknown")
+		assert.Equal(t, models.ErrInvalidCredentials, err)
+	})
+	t.Run("ByUser OK Case - Records Last Use", func(t *testing.T) {
+		tokens, err := repo.ByUser(ctx, 1)
+		assert.NoError(t, err)
+		if assert.Len(t, tokens, 1) {
+			assert.False(t, tokens[0].LastUsed.IsZero())
+		}
+		tokens, err = repo.ByUser(ctx, 2)
+		assert.NoError(t, err)
+		assert.Empty(t, tokens)
+	})
+	t.Run("Revoke NOK Case - Other User", func(t *testing.T) {
+		assert.Equal(t, models.ErrNoRecord, repo.Revoke(ctx, 1, 2))
+	})
+	t.Run("Revoke OK Case", func(t *testing.T) {
+		assert.NoError(t, repo.Revoke(ctx, 1, 1))
+		_, err := repo.Authenticate(ctx, token)
+		assert.Equal(t, models.ErrInvalidCredentials, err)
+	})
+}
+
+func TestHomePageWithMemoryStore(t *testing.T) {
+	snippets := memory.NewSnippetModel()
+	_, err := snippets.Insert(ctx, 1, "Title", "Content", "", models.Public, "", days(7), 0)
+	assert.NoError(t, err)
+
+	templateCache, err := server.NewTemplateCache("../ui/html/")
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	session := sessions.New([]byte(*createSession()))
+	session.Lifetime = 12 * time.Hour
+
+	app := &server.Application{
+		Port:          &port,
+		InfoLog:       infoLog,
+		ErrorLog:      errorLog,
+		Snippets:      snippets,
+		TemplateCache: templateCache,
+		Session:       session,
+		Users:         memory.NewUserModel(),
+	}
+	t.Run("checking home page OK Case", func(t *testing.T) {
+		server, err := server.CreateServer(app)
+		assert.NoError(t, err)
+
+		request := newRequest(http.MethodGet, "")
+		response := httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, request)
+		assertStatus(t, response, http.StatusOK)
+	})
+	t.Run("checking my snippets NOK Case - Not logged in", func(t *testing.T) {
+		server, err := server.CreateServer(app)
+		assert.NoError(t, err)
+
+		request := newRequest(http.MethodGet, "user/snippets")
+		response := httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, request)
+		assertStatus(t, response, http.StatusFound)
+		assert.Equal(t, "/user/login", response.Header().Get("Location"))
+	})
+	t.Run("checking trash NOK Case - Not logged in", func(t *testing.T) {
+		server, err := server.CreateServer(app)
+		assert.NoError(t, err)
+
+		request := newRequest(http.MethodGet, "trash")
+		response := httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, request)
+		assertStatus(t, response, http.StatusFound)
+		assert.Equal(t, "/user/login", response.Header().Get("Location"))
+	})
+	t.Run("checking settings NOK Case - Not logged in", func(t *testing.T) {
+		server, err := server.CreateServer(app)
+		assert.NoError(t, err)
+
+		request := newRequest(http.MethodGet, "user/settings")
+		response := httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, request)
+		assertStatus(t, response, http.StatusFound)
+		assert.Equal(t, "/user/login", response.Header().Get("Location"))
+	})
+	t.Run("checking edit snippet NOK Case - Not logged in", func(t *testing.T) {
+		server, err := server.CreateServer(app)
+		assert.NoError(t, err)
+
+		request := newRequest(http.MethodGet, "snippet/1/edit")
+		response := httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, request)
+		assertStatus(t, response, http.StatusFound)
+		assert.Equal(t, "/user/login", response.Header().Get("Location"))
+	})
+}
+
+func TestSnippetHistoryWithMemoryStore(t *testing.T) {
+	snippets := memory.NewSnippetModel()
+	id, err := snippets.Insert(ctx, 1, "Title", "one\ntwo\n", "", models.Public, "", days(7), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, snippets.Update(ctx, id, 1, "Title", "one\nthree\n", "", models.Public, days(7)))
+
+	templateCache, err := server.NewTemplateCache("../ui/html/")
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	session := sessions.New([]byte(*createSession()))
+	session.Lifetime = 12 * time.Hour
+
+	app := &server.Application{
+		Port:          &port,
+		InfoLog:       infoLog,
+		ErrorLog:      errorLog,
+		Snippets:      snippets,
+		TemplateCache: templateCache,
+		Session:       session,
+		Users:         memory.NewUserModel(),
+	}
+	server, err := server.CreateServer(app)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		testName string
+		path     string
+		status   int
+		body     string
+	}{
+		{"Show OK Case - Line anchors", "snippet/1", http.StatusOK, "<a href='#L2'>2</a>three"},
+		{"History OK Case", "snippet/1/history", http.StatusOK, "Saved by"},
+		{"History NOK Case - No Record", "snippet/2/history", http.StatusNotFound, ""},
+		{"Diff OK Case - Previous revision", "snippet/1/diff?to=2", http.StatusOK, "&#43;three"},
+		{"Diff OK Case - First revision", "snippet/1/diff?to=1", http.StatusOK, "&#43;one"},
+		{"Diff OK Case - From and to", "snippet/1/diff?from=2&to=1", http.StatusOK, "-three"},
+		{"Diff NOK Case - Missing to", "snippet/1/diff", http.StatusBadRequest, ""},
+		{"Diff NOK Case - No Record", "snippet/1/diff?to=9", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			request := newRequest(http.MethodGet, tt.path)
+			response := httptest.NewRecorder()
+			server.Handler.ServeHTTP(response, request)
+			assertStatus(t, response, tt.status)
+			assert.Contains(t, response.Body.String(), tt.body)
+		})
+	}
+}
+
+func TestSnippetListingWithMemoryStore(t *testing.T) {
+	users := memory.NewUserModel()
+	assert.NoError(t, users.Insert("Name", "name@example.com", "C0mpl3xPass!"))
+	snippets := memory.NewSnippetModel()
+	snippets.Users = users
+	for i := 0; i < 3; i++ {
+		_, err := snippets.Insert(ctx, 1, "Title", "Content", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, snippets.SetTags(ctx, 1, []string{"go"}))
+
+	templateCache, err := server.NewTemplateCache("../ui/html/")
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	session := sessions.New([]byte(*createSession()))
+	session.Lifetime = 12 * time.Hour
+
+	app := &server.Application{
+		Port:          &port,
+		InfoLog:       infoLog,
+		ErrorLog:      errorLog,
+		Snippets:      snippets,
+		TemplateCache: templateCache,
+		Session:       session,
+		Users:         users,
+	}
+	server, err := server.CreateServer(app)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		testName string
+		path     string
+		status   int
+		body     string
+	}{
+		{"Listing OK Case", "snippets", http.StatusOK, "#3"},
+		{"Listing OK Case - Next page link", "snippets?limit=2", http.StatusOK, "Next page"},
+		{"Listing OK Case - Author filter", "snippets?author=1", http.StatusOK, "Only snippets by Name"},
+		{"Listing OK Case - Nothing matches", "snippets?to=2001-01-01", http.StatusOK, "No snippets match"},
+		{"Listing NOK Case - Invalid cursor", "snippets?after=nope", http.StatusBadRequest, ""},
+		{"Listing NOK Case - Invalid date", "snippets?from=yesterday", http.StatusBadRequest, ""},
+		{"Tag OK Case", "tag/go", http.StatusOK, "#1"},
+		{"Tag OK Case - Nothing tagged", "tag/nothing", http.StatusOK, "no snippets with this tag"},
+		{"Tag suggestions OK Case", "tags/suggest?prefix=G", http.StatusOK, `["go"]`},
+		{"Tag suggestions OK Case - No match", "tags/suggest?prefix=x", http.StatusOK, `[]`},
+		{"Search OK Case - Form only", "search", http.StatusOK, "Words in the title or content"},
+		{"Search OK Case - Highlighted", "search?q=content", http.StatusOK, "<mark>Content</mark>"},
+		{"Search OK Case - Nothing found", "search?q=nothing", http.StatusOK, "No snippets match"},
+		{"Search NOK Case - Invalid cursor", "search?q=content&after=nope", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			request := newRequest(http.MethodGet, tt.path)
+			response := httptest.NewRecorder()
+			server.Handler.ServeHTTP(response, request)
+			assertStatus(t, response, tt.status)
+			assert.Contains(t, response.Body.String(), tt.body)
+		})
+	}
+}
+
+func TestRawSnippetWithMemoryStore(t *testing.T) {
+	snippets := memory.NewSnippetModel()
+	_, err := snippets.Insert(ctx, 1, "My first snippet!", "package main\n", "go", models.Public, "", days(7), 0)
+	assert.NoError(t, err)
+	_, err = snippets.Insert(ctx, 1, "???", "notes", "", models.Public, "", days(7), 0)
+	assert.NoError(t, err)
+	_, err = snippets.Insert(ctx, 1, "Expired", "gone", "", models.Public, "", days(0), 0)
+	assert.NoError(t, err)
+
+	templateCache, err := server.NewTemplateCache("../ui/html/")
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	session := sessions.New([]byte(*createSession()))
+	session.Lifetime = 12 * time.Hour
+
+	app := &server.Application{
+		Port:          &port,
+		InfoLog:       infoLog,
+		ErrorLog:      errorLog,
+		Snippets:      snippets,
+		TemplateCache: templateCache,
+		Session:       session,
+		Users:         memory.NewUserModel(),
+	}
+	server, err := server.CreateServer(app)
+	assert.NoError(t, err)
+
+	t.Run("Raw OK Case", func(t *testing.T) {
+		request := newRequest(http.MethodGet, "snippet/1/raw")
+		response := httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, request)
+		assertStatus(t, response, http.StatusOK)
+		assert.Equal(t, "package main\n", response.Body.String())
+		assert.Equal(t, "text/plain; charset=utf-8", response.Header().Get("Content-Type"))
+		assert.Equal(t, "13", response.Header().Get("Content-Length"))
+		assert.Equal(t, "private, max-age=300", response.Header().Get("Cache-Control"))
+		assert.Empty(t, response.Header().Get("Content-Disposition"))
+
+		// The ETag lets clients skip the download when nothing changed.
+		request = newRequest(http.MethodGet, "snippet/1/raw")
+		request.Header.Set("If-None-Match", response.Header().Get("ETag"))
+		response = httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, request)
+		assertStatus(t, response, http.StatusNotModified)
+	})
+	t.Run("Raw NOK Case - Expired", func(t *testing.T) {
+		request := newRequest(http.MethodGet, "snippet/3/raw")
+		response := httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, request)
+		assertStatus(t, response, http.StatusNotFound)
+	})
+
+	tests := []struct {
+		testName    string
+		path        string
+		disposition string
+	}{
+		{"Download OK Case", "snippet/1/download", `attachment; filename=my-first-snippet.go`},
+		{"Download OK Case - Title without words", "snippet/2/download", `attachment; filename=snippet-2.txt`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			request := newRequest(http.MethodGet, tt.path)
+			response := httptest.NewRecorder()
+			server.Handler.ServeHTTP(response, request)
+			assertStatus(t, response, http.StatusOK)
+			assert.Equal(t, tt.disposition, response.Header().Get("Content-Disposition"))
+		})
+	}
+}
+
+func TestVisibilityWithMemoryStore(t *testing.T) {
+	snippets := memory.NewSnippetModel()
+	_, err := snippets.Insert(ctx, 1, "Public snippet", "Content", "", models.Public, "", days(7), 0)
+	assert.NoError(t, err)
+	unlisted, err := snippets.Insert(ctx, 1, "Unlisted snippet", "Content", "", models.Unlisted, "", days(7), 0)
+	assert.NoError(t, err)
+	_, err = snippets.Insert(ctx, 1, "Private snippet", "Content", "", models.Private, "", days(7), 0)
+	assert.NoError(t, err)
+	snippet, err := snippets.Get(ctx, unlisted)
+	assert.NoError(t, err)
+
+	templateCache, err := server.NewTemplateCache("../ui/html/")
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	session := sessions.New([]byte(*createSession()))
+	session.Lifetime = 12 * time.Hour
+
+	app := &server.Application{
+		Port:          &port,
+		InfoLog:       infoLog,
+		ErrorLog:      errorLog,
+		Snippets:      snippets,
+		TemplateCache: templateCache,
+		Session:       session,
+		Users:         memory.NewUserModel(),
+	}
+	server, err := server.CreateServer(app)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		testName string
+		path     string
+		status   int
+		contains string
+	}{
+		{"Show OK Case - Public", "snippet/1", http.StatusOK, "Public snippet"},
+		{"Show NOK Case - Unlisted by ID", "snippet/2", http.StatusNotFound, ""},
+		{"Show OK Case - Unlisted by slug", "s/" + snippet.Slug, http.StatusOK, "Unlisted snippet"},
+		{"Raw OK Case - Unlisted by slug", "s/" + snippet.Slug + "/raw", http.StatusOK, "Content"},
+		{"Raw NOK Case - Unlisted by ID", "snippet/2/raw", http.StatusNotFound, ""},
+		{"Show NOK Case - Private", "snippet/3", http.StatusNotFound, ""},
+		{"Show NOK Case - Unknown slug", "s/nope", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			request := newRequest(http.MethodGet, tt.path)
+			response := httptest.NewRecorder()
+			server.Handler.ServeHTTP(response, request)
+			assertStatus(t, response, tt.status)
+			assert.Contains(t, response.Body.String(), tt.contains)
+		})
+	}
+	t.Run("Home OK Case - Only public snippets", func(t *testing.T) {
+		request := newRequest(http.MethodGet, "")
+		response := httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, request)
+		assertStatus(t, response, http.StatusOK)
+		assert.Contains(t, response.Body.String(), "Public snippet")
+		assert.NotContains(t, response.Body.String(), "Unlisted snippet")
+		assert.NotContains(t, response.Body.String(), "Private snippet")
+	})
+}
+
+func TestProtectedSnippetWithMemoryStore(t *testing.T) {
+	snippets := memory.NewSnippetModel()
+	_, err := snippets.Insert(ctx, 1, "Contractor config", "password=hunter2", "", models.Public, "s3cret", days(7), 0)
+	assert.NoError(t, err)
+
+	templateCache, err := server.NewTemplateCache("../ui/html/")
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	session := sessions.New([]byte(*createSession()))
+	session.Lifetime = 12 * time.Hour
+
+	app := &server.Application{
+		Port:          &port,
+		InfoLog:       infoLog,
+		ErrorLog:      errorLog,
+		Snippets:      snippets,
+		TemplateCache: templateCache,
+		Session:       session,
+		Users:         memory.NewUserModel(),
+	}
+	server, err := server.CreateServer(app)
+	assert.NoError(t, err)
+
+	for _, path := range []string{"snippet/1", "snippet/1/raw", "snippet/1/download", "snippet/1/history"} {
+		t.Run("Locked NOK Case - "+path, func(t *testing.T) {
+			request := newRequest(http.MethodGet, path)
+			response := httptest.NewRecorder()
+			server.Handler.ServeHTTP(response, request)
+			assertStatus(t, response, http.StatusForbidden)
+			assert.Contains(t, response.Body.String(), "action='/snippet/1/unlock'")
+			assert.NotContains(t, response.Body.String(), "hunter2")
+			assert.NotContains(t, response.Body.String(), "Contractor config")
+		})
+	}
+}
+
+func TestBurnAfterReadingWithMemoryStore(t *testing.T) {
+	snippets := memory.NewSnippetModel()
+	_, err := snippets.Insert(ctx, 1, "One time secret", "token=abc123", "", models.Public, "", days(7), 1)
+	assert.NoError(t, err)
+	burned, err := snippets.Insert(ctx, 1, "Burned", "Content", "", models.Public, "", days(7), 1)
+	assert.NoError(t, err)
+	_, err = snippets.View(ctx, burned)
+	assert.NoError(t, err)
+
+	templateCache, err := server.NewTemplateCache("../ui/html/")
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	session := sessions.New([]byte(*createSession()))
+	session.Lifetime = 12 * time.Hour
+
+	app := &server.Application{
+		Port:          &port,
+		InfoLog:       infoLog,
+		ErrorLog:      errorLog,
+		Snippets:      snippets,
+		TemplateCache: templateCache,
+		Session:       session,
+		Users:         memory.NewUserModel(),
+	}
+	server, err := server.CreateServer(app)
+	assert.NoError(t, err)
+
+	for _, path := range []string{"snippet/1", "snippet/1/raw", "snippet/1/download"} {
+		t.Run("Confirm OK Case - "+path, func(t *testing.T) {
+			request := newRequest(http.MethodGet, path)
+			response := httptest.NewRecorder()
+			server.Handler.ServeHTTP(response, request)
+			assertStatus(t, response, http.StatusOK)
+			assert.Contains(t, response.Body.String(), "action='/snippet/1/view'")
+			assert.NotContains(t, response.Body.String(), "abc123")
+		})
+	}
+	t.Run("Confirm OK Case - GET doesn't count as a view", func(t *testing.T) {
+		snippet, err := snippets.Get(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, snippet.ViewsLeft)
+	})
+	t.Run("Show NOK Case - Burned", func(t *testing.T) {
+		request := newRequest(http.MethodGet, fmt.Sprintf("snippet/%d", burned))
+		response := httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, request)
+		assertStatus(t, response, http.StatusGone)
+		assert.Contains(t, response.Body.String(), "This snippet is gone")
+	})
+	t.Run("Show NOK Case - Never existed", func(t *testing.T) {
+		request := newRequest(http.MethodGet, "snippet/100")
+		response := httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, request)
+		assertStatus(t, response, http.StatusNotFound)
+	})
+}
+
+func TestCursor(t *testing.T) {
+	cursor := &models.Cursor{Created: time.Date(2024, 2, 23, 10, 23, 42, 123456000, time.UTC), ID: 7}
+	parsed, err := models.ParseCursor(cursor.String())
+	assert.NoError(t, err)
+	assert.Equal(t, cursor, parsed)
+
+	_, err = models.ParseCursor("bm9wZQ")
+	assert.Equal(t, models.ErrInvalidCursor, err)
+}
+
+func TestSnippetEditableBy(t *testing.T) {
+	snippet := &models.Snippet{ID: 1, UserID: 1}
+	assert.True(t, snippet.EditableBy(&models.User{ID: 1}))
+	assert.False(t, snippet.EditableBy(&models.User{ID: 2}))
+	assert.True(t, snippet.EditableBy(&models.User{ID: 2, Admin: true}))
+	assert.False(t, snippet.EditableBy(nil))
+	assert.False(t, (&models.Snippet{ID: 2}).EditableBy(&models.User{ID: 2}))
+}
+
+func TestSnippetVisibleTo(t *testing.T) {
+	for _, visibility := range []string{models.Unlisted, models.Private} {
+		snippet := &models.Snippet{ID: 1, UserID: 1, Visibility: visibility}
+		assert.True(t, snippet.VisibleTo(&models.User{ID: 1}))
+		assert.True(t, snippet.VisibleTo(&models.User{ID: 2, Admin: true}))
+		assert.False(t, snippet.VisibleTo(&models.User{ID: 2}))
+		assert.False(t, snippet.VisibleTo(nil))
+	}
+	assert.True(t, (&models.Snippet{ID: 1, Visibility: models.Public}).VisibleTo(nil))
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"database/sql"
+	"errors"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"snippetbox/pkg/migrations"
+	"testing"
+)
+
+func newMigrator(t *testing.T) (*sql.DB, *migrations.Migrator) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	migrator, err := migrations.New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, migrator
+}
+
+func tableExists(db *sql.DB, name string) bool {
+	var found string
+	err := db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&found)
+	return err == nil
+}
+
+func TestMigrations(t *testing.T) {
+	t.Run("New NOK Case - Unknown dialect", func(t *testing.T) {
+		_, err := migrations.New(nil, "oracle")
+		assert.Error(t, err)
+	})
+	t.Run("Every dialect has the same versions", func(t *testing.T) {
+		want := 0
+		for _, dialect := range []string{"mysql", "postgres", "sqlite"} {
+			migrator, err := migrations.New(nil, dialect)
+			assert.NoError(t, err)
+			if want == 0 {
+				want = migrator.Latest()
+			}
+			assert.Equal(t, want, migrator.Latest(), dialect)
+		}
+	})
+	t.Run("Up OK Case", func(t *testing.T) {
+		db, migrator := newMigrator(t)
+		defer db.Close()
+
+		assert.NoError(t, migrator.Up(ctx))
+		version, err := migrator.Version(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, migrator.Latest(), version)
+		assert.True(t, tableExists(db, "snippets"))
+		assert.True(t, tableExists(db, "users"))
+		assert.NoError(t, migrator.CheckCurrent(ctx))
+
+		// Running it again is a no-op.
+		assert.NoError(t, migrator.Up(ctx))
+	})
+	t.Run("Down OK Case", func(t *testing.T) {
+		db, migrator := newMigrator(t)
+		defer db.Close()
+
+		assert.NoError(t, migrator.Up(ctx))
+		assert.NoError(t, migrator.Down(ctx))
+		version, err := migrator.Version(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, migrator.Latest()-1, version)
+		assert.ErrorIs(t, migrator.CheckCurrent(ctx), migrations.ErrOutOfDate)
+	})
+	t.Run("To OK Case - Up and back down to 0", func(t *testing.T) {
+		db, migrator := newMigrator(t)
+		defer db.Close()
+
+		assert.NoError(t, migrator.To(ctx, 1))
+		assert.True(t, tableExists(db, "snippets"))
+		assert.False(t, tableExists(db, "users"))
+
+		assert.NoError(t, migrator.To(ctx, 0))
+		assert.False(t, tableExists(db, "snippets"))
+	})
+	t.Run("To NOK Case - Unknown version", func(t *testing.T) {
+		db, migrator := newMigrator(t)
+		defer db.Close()
+
+		assert.Error(t, migrator.To(ctx, migrator.Latest()+1))
+	})
+	t.Run("Status OK Case", func(t *testing.T) {
+		db, migrator := newMigrator(t)
+		defer db.Close()
+
+		assert.NoError(t, migrator.To(ctx, 1))
+		statuses, err := migrator.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, statuses, migrator.Latest())
+		assert.True(t, statuses[0].Applied)
+		assert.False(t, statuses[0].AppliedAt.IsZero())
+		assert.False(t, statuses[1].Applied)
+	})
+	t.Run("CheckCurrent NOK Case - Out of date", func(t *testing.T) {
+		db, migrator := newMigrator(t)
+		defer db.Close()
+
+		err := migrator.CheckCurrent(ctx)
+		assert.True(t, errors.Is(err, migrations.ErrOutOfDate))
+	})
+}
@@ -0,0 +1,203 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"snippetbox/pkg/models"
+	"snippetbox/pkg/models/memory"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Checks value, as decoded by encoding/json, against the subset of JSON
+// schema used by /api/openapi.json. Objects may only hold the properties
+// their schema describes, so that a field added to a model without the
+// document noticing fails the test.
+func validateSchema(spec map[string]interface{}, schema map[string]interface{}, value interface{}, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved := spec
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			next, ok := resolved[part].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: unresolved %s", path, ref)
+			}
+			resolved = next
+		}
+		return validateSchema(spec, resolved, value, path)
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: %v is not an object", path, value)
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing %s", path, name)
+			}
+		}
+		for name, v := range object {
+			propertySchema, ok := properties[name].(map[string]interface{})
+			if !ok {
+				propertySchema, ok = schema["additionalProperties"].(map[string]interface{})
+			}
+			if !ok {
+				if properties == nil {
+					continue
+				}
+				return fmt.Errorf("%s: %s is not in the schema", path, name)
+			}
+			if err := validateSchema(spec, propertySchema, v, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: %v is not an array", path, value)
+		}
+		for i, v := range array {
+			if err := validateSchema(spec, schema["items"].(map[string]interface{}), v, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: %v is not a string", path, value)
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return fmt.Errorf("%s: %s", path, err)
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s: %v is not an integer", path, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: %v is not a boolean", path, value)
+		}
+	default:
+		return fmt.Errorf("%s: unknown type %v", path, schema["type"])
+	}
+	return nil
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	snippets := memory.NewSnippetModel()
+	public, err := snippets.Insert(ctx, 1, "Public snippet", "Content", "go", models.Public, "", days(7), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, snippets.SetTags(ctx, public, []string{"go"}))
+	burn, err := snippets.Insert(ctx, 1, "Burn snippet", "Content", "", models.Public, "", days(7), 1)
+	assert.NoError(t, err)
+	users := memory.NewUserModel()
+	assert.NoError(t, users.Insert("Name", "name@example.com", "C0mpl3xPass!"))
+	tokens := memory.NewTokenModel()
+	writeToken, err := tokens.Insert(ctx, 1, "Write", models.ScopeWrite)
+	assert.NoError(t, err)
+	readToken, err := tokens.Insert(ctx, 1, "Read", models.ScopeRead)
+	assert.NoError(t, err)
+	srv := newAPIServer(t, snippets, users, tokens)
+
+	request := httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil)
+	response := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(response, request)
+	assertStatus(t, response, http.StatusOK)
+	spec := map[string]interface{}{}
+	if err := json.NewDecoder(response.Body).Decode(&spec); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "3.0.3", spec["openapi"])
+	snippetSchema := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})["Snippet"].(map[string]interface{})
+	assert.NotContains(t, snippetSchema["properties"], "hashed_password")
+	assert.NotContains(t, snippetSchema["properties"], "HashedPassword")
+
+	id := func(n int) string { return "/api/v1/snippets/" + strconv.Itoa(n) }
+	created := strconv.Itoa(burn + 1)
+	tests := []struct {
+		name        string
+		method      string
+		route, path string
+		token       string
+		body        string
+		status      int
+	}{
+		{"List snippets", http.MethodGet, "/api/v1/snippets", "/api/v1/snippets", "", "", http.StatusOK},
+		{"List snippets - Empty page", http.MethodGet, "/api/v1/snippets", "/api/v1/snippets?author=100", "", "", http.StatusOK},
+		{"List snippets - Bad filter", http.MethodGet, "/api/v1/snippets", "/api/v1/snippets?author=nobody", "", "", http.StatusBadRequest},
+		{"List snippets - Bad token", http.MethodGet, "/api/v1/snippets", "/api/v1/snippets", "sb_unknown", "", http.StatusUnauthorized},
+		{"Get snippet", http.MethodGet, "/api/v1/snippets/{id}", id(public), "", "", http.StatusOK},
+		{"Get snippet - Burned", http.MethodGet, "/api/v1/snippets/{id}", id(burn), "", "", http.StatusOK},
+		{"Get snippet - Gone", http.MethodGet, "/api/v1/snippets/{id}", id(burn), "", "", http.StatusGone},
+		{"Get snippet - Not found", http.MethodGet, "/api/v1/snippets/{id}", id(100), "", "", http.StatusNotFound},
+		{"Get snippet - Malformed ID", http.MethodGet, "/api/v1/snippets/{id}", "/api/v1/snippets/abc", "", "", http.StatusBadRequest},
+		{"Create snippet", http.MethodPost, "/api/v1/snippets", "/api/v1/snippets", writeToken, `{"title": "Title", "content": "Content", "expires": "7", "tags": ["go"]}`, http.StatusCreated},
+		{"Create snippet - Invalid", http.MethodPost, "/api/v1/snippets", "/api/v1/snippets", writeToken, `{"title": "Title"}`, http.StatusUnprocessableEntity},
+		{"Create snippet - Malformed", http.MethodPost, "/api/v1/snippets", "/api/v1/snippets", writeToken, `{"title": 1}`, http.StatusBadRequest},
+		{"Create snippet - Read token", http.MethodPost, "/api/v1/snippets", "/api/v1/snippets", readToken, `{"title": "Title"}`, http.StatusForbidden},
+		{"Create snippet - Not logged in", http.MethodPost, "/api/v1/snippets", "/api/v1/snippets", "", `{"title": "Title"}`, http.StatusUnauthorized},
+		{"Update snippet", http.MethodPut, "/api/v1/snippets/{id}", "/api/v1/snippets/" + created, writeToken, `{"title": "New title", "content": "Content", "expires": "1"}`, http.StatusOK},
+		{"Update snippet - Not found", http.MethodPut, "/api/v1/snippets/{id}", id(100), writeToken, `{"title": "Title"}`, http.StatusNotFound},
+		{"Delete snippet", http.MethodDelete, "/api/v1/snippets/{id}", "/api/v1/snippets/" + created, writeToken, "", http.StatusNoContent},
+		{"Delete snippet - Not found", http.MethodDelete, "/api/v1/snippets/{id}", "/api/v1/snippets/" + created, writeToken, "", http.StatusNotFound},
+		{"Get user", http.MethodGet, "/api/v1/user", "/api/v1/user", readToken, "", http.StatusOK},
+		{"Get user - Not logged in", http.MethodGet, "/api/v1/user", "/api/v1/user", "", "", http.StatusUnauthorized},
+		{"Get spec", http.MethodGet, "/api/openapi.json", "/api/openapi.json", "", "", http.StatusOK},
+	}
+
+	tested := map[string]bool{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			request.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				request.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			response := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(response, request)
+			assertStatus(t, response, tt.status)
+
+			operation, ok := spec["paths"].(map[string]interface{})[tt.route].(map[string]interface{})[strings.ToLower(tt.method)].(map[string]interface{})
+			if !assert.True(t, ok, "%s %s is not in the spec", tt.method, tt.route) {
+				return
+			}
+			tested[tt.method+" "+tt.route] = true
+			described, ok := operation["responses"].(map[string]interface{})[strconv.Itoa(tt.status)].(map[string]interface{})
+			if !assert.True(t, ok, "%d is not a response of %s %s", tt.status, tt.method, tt.route) {
+				return
+			}
+
+			content, ok := described["content"].(map[string]interface{})
+			if !ok {
+				assert.Empty(t, response.Body.String())
+				return
+			}
+			assert.Equal(t, "application/json", response.Header().Get("Content-Type"))
+			var body interface{}
+			assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+			schema := content["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+			assert.NoError(t, validateSchema(spec, schema, body, "body"))
+		})
+	}
+
+	// Every route of the spec was checked against a real response.
+	for route, item := range spec["paths"].(map[string]interface{}) {
+		for method := range item.(map[string]interface{}) {
+			if method != "parameters" {
+				assert.True(t, tested[strings.ToUpper(method)+" "+route], "%s %s is not tested", method, route)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/golangcollege/sessions"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"snippetbox/cmd/server"
+	"snippetbox/pkg/models"
+	"snippetbox/pkg/models/memory"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns an application accepting pastes of at most 64 bytes, 3 per
+// minute and IP address, into the memory store.
+func newPasteApp(t *testing.T, snippets *memory.SnippetModel) *server.Application {
+	t.Helper()
+	session := sessions.New([]byte(*createSession()))
+	session.Lifetime = 12 * time.Hour
+	return &server.Application{
+		Port:         &port,
+		InfoLog:      infoLog,
+		ErrorLog:     errorLog,
+		Snippets:     snippets,
+		Session:      session,
+		Users:        memory.NewUserModel(),
+		Paste:        true,
+		BaseURL:      "https://snippets.example.com",
+		MaxPasteSize: 64,
+		PasteLimiter: server.NewRateLimiter(3, time.Minute),
+	}
+}
+
+// Returns the snippet of a paste URL.
+func pastedSnippet(t *testing.T, snippets *memory.SnippetModel, url string) *models.Snippet {
+	t.Helper()
+	url = strings.TrimSpace(url)
+	if !assert.True(t, strings.HasPrefix(url, "https://snippets.example.com/s/"), url) {
+		t.FailNow()
+	}
+	snippet, err := snippets.BySlug(ctx, strings.TrimPrefix(url, "https://snippets.example.com/s/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snippet
+}
+
+func TestPaste(t *testing.T) {
+	snippets := memory.NewSnippetModel()
+	srv, err := server.CreateServer(newPasteApp(t, snippets))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(remoteAddr, contentType string, body io.Reader) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/paste", body)
+		request.RemoteAddr = remoteAddr
+		if contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+		response := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(response, request)
+		return response
+	}
+	multipartBody := func(field, filename, content string) (string, io.Reader) {
+		body := &bytes.Buffer{}
+		w := multipart.NewWriter(body)
+		part, err := w.CreateFormFile(field, filename)
+		assert.NoError(t, err)
+		io.WriteString(part, content)
+		assert.NoError(t, w.Close())
+		return w.FormDataContentType(), body
+	}
+
+	t.Run("Raw body OK Case", func(t *testing.T) {
+		response := serve("192.0.2.1:1234", "", strings.NewReader("echo hello"))
+		assertStatus(t, response, http.StatusCreated)
+		assert.Equal(t, "text/plain; charset=utf-8", response.Header().Get("Content-Type"))
+		assert.Equal(t, strings.TrimSpace(response.Body.String()), response.Header().Get("Location"))
+
+		snippet := pastedSnippet(t, snippets, response.Body.String())
+		assert.Equal(t, "Paste", snippet.Title)
+		assert.Equal(t, "echo hello", snippet.Content)
+		assert.Equal(t, models.Unlisted, snippet.Visibility)
+		assert.Zero(t, snippet.UserID)
+	})
+	t.Run("Multipart file OK Case", func(t *testing.T) {
+		contentType, body := multipartBody("f", "main.go", "package main")
+		response := serve("192.0.2.2:1234", contentType, body)
+		assertStatus(t, response, http.StatusCreated)
+
+		snippet := pastedSnippet(t, snippets, response.Body.String())
+		assert.Equal(t, "main.go", snippet.Title)
+		assert.Equal(t, "go", snippet.Language)
+	})
+
+	errorTests := []struct {
+		name   string
+		body   func() (string, io.Reader)
+		status int
+	}{
+		{"Raw body NOK Case - Empty", func() (string, io.Reader) { return "", strings.NewReader(" \n") }, http.StatusBadRequest},
+		{"Raw body NOK Case - Binary", func() (string, io.Reader) { return "", strings.NewReader("\xff\xfe\x00") }, http.StatusBadRequest},
+		{"Raw body NOK Case - Too large", func() (string, io.Reader) { return "", strings.NewReader(strings.Repeat("a", 65)) }, http.StatusRequestEntityTooLarge},
+		{"Multipart file NOK Case - Too large", func() (string, io.Reader) { return multipartBody("f", "big.txt", strings.Repeat("a", 65)) }, http.StatusRequestEntityTooLarge},
+		{"Multipart file NOK Case - Wrong field", func() (string, io.Reader) { return multipartBody("file", "main.go", "package main") }, http.StatusBadRequest},
+	}
+	for i, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType, body := tt.body()
+			response := serve(fmt.Sprintf("198.51.100.%d:1234", i), contentType, body)
+			assertStatus(t, response, tt.status)
+			assert.NotEmpty(t, response.Body.String())
+		})
+	}
+
+	t.Run("NOK Case - Rate limited", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			assertStatus(t, serve("192.0.2.4:1234", "", strings.NewReader("hello")), http.StatusCreated)
+		}
+		response := serve("192.0.2.4:4321", "", strings.NewReader("hello"))
+		assertStatus(t, response, http.StatusTooManyRequests)
+	})
+	t.Run("NOK Case - Not enabled", func(t *testing.T) {
+		app := newPasteApp(t, snippets)
+		app.Paste = false
+		srv, err := server.CreateServer(app)
+		assert.NoError(t, err)
+
+		request := httptest.NewRequest(http.MethodPost, "/paste", strings.NewReader("hello"))
+		response := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(response, request)
+		assert.NotEqual(t, http.StatusCreated, response.Code)
+	})
+}
+
+func TestPasteListener(t *testing.T) {
+	snippets := memory.NewSnippetModel()
+	app := newPasteApp(t, snippets)
+	if _, err := server.CreateServer(app); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listenerCtx, stop := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- app.ServePastes(listenerCtx, l) }()
+
+	paste := func(t *testing.T, content string, closeWrite bool) string {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		io.WriteString(conn, content)
+		if closeWrite {
+			conn.(*net.TCPConn).CloseWrite()
+		}
+		answer, err := io.ReadAll(conn)
+		assert.NoError(t, err)
+		return string(answer)
+	}
+
+	t.Run("OK Case - Until EOF", func(t *testing.T) {
+		snippet := pastedSnippet(t, snippets, paste(t, "echo hello", true))
+		assert.Equal(t, "echo hello", snippet.Content)
+	})
+	t.Run("OK Case - Until idle", func(t *testing.T) {
+		snippet := pastedSnippet(t, snippets, paste(t, "echo idle", false))
+		assert.Equal(t, "echo idle", snippet.Content)
+	})
+	t.Run("NOK Case - Too large", func(t *testing.T) {
+		assert.Equal(t, "the paste is too large\n", paste(t, strings.Repeat("a", 65), true))
+	})
+
+	stop()
+	assert.NoError(t, <-done)
+}
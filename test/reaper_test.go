@@ -0,0 +1,80 @@
+package test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"snippetbox/cmd/server"
+	"snippetbox/pkg/models"
+	"snippetbox/pkg/models/memory"
+	"testing"
+	"time"
+)
+
+func TestReaper(t *testing.T) {
+	t.Run("Reap OK Case - Batches and grace period", func(t *testing.T) {
+		snippets := memory.NewSnippetModel()
+		for i := 0; i < 5; i++ {
+			_, err := snippets.Insert(ctx, 1, "Old", "Content", "", models.Public, "", days(-10), 0)
+			assert.NoError(t, err)
+		}
+		recent, err := snippets.Insert(ctx, 1, "Recent", "Content", "", models.Public, "", days(-1), 0)
+		assert.NoError(t, err)
+		live, err := snippets.Insert(ctx, 1, "Live", "Content", "", models.Public, "", days(1), 0)
+		assert.NoError(t, err)
+
+		reaper := server.NewReaper(snippets, time.Hour, 7*24*time.Hour, infoLog, errorLog)
+		reaper.BatchSize = 2
+		n, err := reaper.Reap(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, n)
+
+		left, err := snippets.ByUser(ctx, 1)
+		assert.NoError(t, err)
+		ids := []int{}
+		for _, s := range left {
+			ids = append(ids, s.ID)
+		}
+		assert.ElementsMatch(t, []int{recent, live}, ids)
+
+		n, err = reaper.Reap(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+	})
+	t.Run("Reap OK Case - Trash retention", func(t *testing.T) {
+		snippets := memory.NewSnippetModel()
+		id, err := snippets.Insert(ctx, 1, "Deleted", "Content", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+		assert.NoError(t, snippets.Delete(ctx, id))
+
+		reaper := server.NewReaper(snippets, time.Hour, 0, infoLog, errorLog)
+		n, err := reaper.Reap(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+
+		reaper.Retention = -time.Minute
+		n, err = reaper.Reap(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		trash, err := snippets.Trash(ctx, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, trash)
+	})
+	t.Run("Run OK Case - Stops on cancel", func(t *testing.T) {
+		snippets := memory.NewSnippetModel()
+		_, err := snippets.Insert(ctx, 1, "Old", "Content", "", models.Public, "", days(-10), 0)
+		assert.NoError(t, err)
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			server.NewReaper(snippets, time.Hour, 0, infoLog, errorLog).Run(runCtx)
+			close(done)
+		}()
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("the reaper didn't stop")
+		}
+	})
+}
@@ -0,0 +1,694 @@
+package test
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"snippetbox/pkg/migrations"
+	"snippetbox/pkg/models"
+	"snippetbox/pkg/models/sqlite"
+	"testing"
+	"time"
+)
+
+func newSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a brand new database.
+	db.SetMaxOpenConns(1)
+	migrator, err := migrations.New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLiteSnippetModel(t *testing.T) {
+	db := newSQLiteDB(t)
+	repo, err := sqlite.NewSnippetModel(db, infoLog, errorLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	users := &sqlite.UserModel{DB: db}
+	if err := users.Insert("Name", "name@example.com", "C0mpl3xPass!"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Insert and Get OK Case", func(t *testing.T) {
+		id, err := repo.Insert(ctx, 1, "Title", "Content", "go", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+
+		snippet, err := repo.Get(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, "Title", snippet.Title)
+		assert.Equal(t, "go", snippet.Language)
+		assert.Equal(t, 1, snippet.UserID)
+		assert.Equal(t, "Name", snippet.Author)
+		assert.True(t, snippet.Expires.After(snippet.Created))
+	})
+	t.Run("Insert OK Case - Anonymous", func(t *testing.T) {
+		id, err := repo.Insert(ctx, 0, "Anonymous", "Content", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+
+		snippet, err := repo.Get(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, snippet.UserID)
+		assert.Equal(t, "", snippet.Author)
+	})
+	t.Run("Get NOK Case - Expired", func(t *testing.T) {
+		id, err := repo.Insert(ctx, 1, "Expired", "Content", "", models.Public, "", days(0), 0)
+		assert.NoError(t, err)
+
+		snippet, err := repo.Get(ctx, id)
+		assert.Nil(t, snippet)
+		assert.Equal(t, models.ErrNoRecord, err)
+	})
+	t.Run("Get NOK Case - No Record", func(t *testing.T) {
+		snippet, err := repo.Get(ctx, 100)
+		assert.Nil(t, snippet)
+		assert.Equal(t, models.ErrNoRecord, err)
+	})
+	t.Run("List OK Case - Expired skipped", func(t *testing.T) {
+		page, err := repo.List(ctx, models.ListOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, page.Snippets, 2)
+		for _, s := range page.Snippets {
+			assert.NotEqual(t, "Expired", s.Title)
+		}
+	})
+	t.Run("List OK Case - Filters", func(t *testing.T) {
+		page, err := repo.List(ctx, models.ListOptions{UserID: 1})
+		assert.NoError(t, err)
+		assert.Len(t, page.Snippets, 1)
+
+		page, err = repo.List(ctx, models.ListOptions{ExpiresBefore: time.Now().Add(time.Hour)})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Snippets)
+
+		page, err = repo.List(ctx, models.ListOptions{
+			CreatedAfter:  time.Now().Add(-time.Hour),
+			CreatedBefore: time.Now().Add(time.Hour),
+		})
+		assert.NoError(t, err)
+		assert.Len(t, page.Snippets, 2)
+	})
+	t.Run("ByUser OK Case - Expired included", func(t *testing.T) {
+		snippets, err := repo.ByUser(ctx, 1)
+		assert.NoError(t, err)
+		assert.Len(t, snippets, 2)
+		for _, s := range snippets {
+			assert.Equal(t, 1, s.UserID)
+		}
+	})
+	t.Run("Update OK Case - Expiry restarted", func(t *testing.T) {
+		id, err := repo.Insert(ctx, 1, "Expired", "Content", "", models.Public, "", days(0), 0)
+		assert.NoError(t, err)
+
+		assert.NoError(t, repo.Update(ctx, id, 1, "Revived", "Changed", "sql", models.Public, days(7)))
+		snippet, err := repo.Get(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, "Revived", snippet.Title)
+		assert.Equal(t, "sql", snippet.Language)
+		assert.Equal(t, "Changed", snippet.Content)
+	})
+	t.Run("Update NOK Case - No Record", func(t *testing.T) {
+		assert.Equal(t, models.ErrNoRecord, repo.Update(ctx, 100, 1, "Title", "Content", "", models.Public, days(7)))
+	})
+	t.Run("Delete OK Case", func(t *testing.T) {
+		id, err := repo.Insert(ctx, 1, "Doomed", "Content", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+
+		assert.NoError(t, repo.Delete(ctx, id))
+		_, err = repo.Get(ctx, id)
+		assert.Equal(t, models.ErrNoRecord, err)
+		assert.Equal(t, models.ErrNoRecord, repo.Delete(ctx, id))
+	})
+	t.Run("Revisions OK Case - Saved on insert, update and restore", func(t *testing.T) {
+		id, err := repo.Insert(ctx, 1, "First", "one", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Update(ctx, id, 1, "Second", "two", "", models.Public, days(7)))
+
+		revisions, err := repo.Revisions(ctx, id)
+		assert.NoError(t, err)
+		assert.Len(t, revisions, 2)
+		assert.Equal(t, "Second", revisions[0].Title)
+		assert.Equal(t, "Name", revisions[0].Author)
+
+		assert.NoError(t, repo.Restore(ctx, id, revisions[1].ID, 1))
+		snippet, err := repo.Get(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, "First", snippet.Title)
+		assert.Equal(t, "one", snippet.Content)
+
+		revisions, err = repo.Revisions(ctx, id)
+		assert.NoError(t, err)
+		assert.Len(t, revisions, 3)
+		assert.Equal(t, "First", revisions[0].Title)
+
+		revision, err := repo.Revision(ctx, id, revisions[1].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "two", revision.Content)
+
+		// The trash keeps the revisions, purging deletes them.
+		assert.NoError(t, repo.Delete(ctx, id))
+		revisions, err = repo.Revisions(ctx, id)
+		assert.NoError(t, err)
+		assert.Len(t, revisions, 3)
+		assert.NoError(t, repo.Purge(ctx, id))
+		revisions, err = repo.Revisions(ctx, id)
+		assert.NoError(t, err)
+		assert.Empty(t, revisions)
+	})
+	t.Run("Restore NOK Case - Revision of another snippet", func(t *testing.T) {
+		first, err := repo.Insert(ctx, 1, "First", "Content", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+		second, err := repo.Insert(ctx, 1, "Second", "Content", "", models.Public, "", days(7), 0)
+		assert.NoError(t, err)
+		revisions, err := repo.Revisions(ctx, first)
+		assert.NoError(t, err)
+
+		assert.Equal(t, models.ErrNoRecord, repo.Restore(ctx, second, revisions[0].ID, 1))
+	})
+}
+
+// Snippets inserted within the same second share their creation time, so
+// the pages only line up if the cursor falls back on the ID.
+func TestSQLiteExpiry(t *testing.T) {
+	db := newSQLiteDB(t)
+	repo, err := sqlite.NewSnippetModel(db, infoLog, errorLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	expires := time.Date(2100, 1, 2, 3, 4, 0, 0, time.UTC)
+	exact, err := repo.Insert(ctx, 0, "Exact", "Content", "", models.Public, "", expires, 0)
+	assert.NoError(t, err)
+	forever, err := repo.Insert(ctx, 0, "Forever", "Content", "", models.Public, "", models.Never, 0)
+	assert.NoError(t, err)
+
+	snippet, err := repo.Get(ctx, exact)
+	assert.NoError(t, err)
+	assert.True(t, expires.Equal(snippet.Expires))
+	assert.False(t, snippet.NeverExpires())
+
+	snippet, err = repo.Get(ctx, forever)
+	assert.NoError(t, err)
+	assert.True(t, snippet.NeverExpires())
+
+	assert.NoError(t, repo.Update(ctx, exact, 0, "Exact", "Content", "", models.Public, models.Never))
+	snippet, err = repo.Get(ctx, exact)
+	assert.NoError(t, err)
+	assert.True(t, snippet.NeverExpires())
+
+	t.Run("DeleteExpired OK Case", func(t *testing.T) {
+		first, err := repo.Insert(ctx, 0, "First", "Content", "", models.Public, "", days(-3), 0)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.SetTags(ctx, first, []string{"go"}))
+		second, err := repo.Insert(ctx, 0, "Second", "Content", "", models.Public, "", days(-2), 0)
+		assert.NoError(t, err)
+
+		n, err := repo.DeleteExpired(ctx, time.Now(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		revisions, err := repo.Revisions(ctx, first)
+		assert.NoError(t, err)
+		assert.Empty(t, revisions)
+		revisions, err = repo.Revisions(ctx, second)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, revisions)
+
+		n, err = repo.DeleteExpired(ctx, time.Now(), 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		_, err = repo.Get(ctx, forever)
+		assert.NoError(t, err)
+	})
+}
+
+func TestSQLiteTrash(t *testing.T) {
+	db := newSQLiteDB(t)
+	repo, err := sqlite.NewSnippetModel(db, infoLog, errorLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	deleted, err := repo.Insert(ctx, 1, "Go trash", "Content", "", models.Public, "", days(7), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.SetTags(ctx, deleted, []string{"trash"}))
+	kept, err := repo.Insert(ctx, 1, "Go kept", "Content", "", models.Public, "", days(7), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Delete(ctx, deleted))
+
+	t.Run("Deleted snippets don't leak", func(t *testing.T) {
+		_, err := repo.Get(ctx, deleted)
+		assert.Equal(t, models.ErrNoRecord, err)
+
+		page, err := repo.List(ctx, models.ListOptions{})
+		assert.NoError(t, err)
+		if assert.Len(t, page.Snippets, 1) {
+			assert.Equal(t, kept, page.Snippets[0].ID)
+		}
+
+		page, err = repo.Search(ctx, "go", models.ListOptions{})
+		assert.NoError(t, err)
+		if assert.Len(t, page.Snippets, 1) {
+			assert.Equal(t, kept, page.Snippets[0].ID)
+		}
+		page, err = repo.Search(ctx, "trash", models.ListOptions{})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Snippets)
+
+		page, err = repo.List(ctx, models.ListOptions{Tag: "trash"})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Snippets)
+
+		tags, err := repo.SuggestTags(ctx, "tr", 5)
+		assert.NoError(t, err)
+		assert.Empty(t, tags)
+
+		snippets, err := repo.ByUser(ctx, 1)
+		assert.NoError(t, err)
+		assert.Len(t, snippets, 1)
+
+		assert.Equal(t, models.ErrNoRecord, repo.Update(ctx, deleted, 1, "Title", "Content", "", models.Public, days(7)))
+		assert.Equal(t, models.ErrNoRecord, repo.Delete(ctx, deleted))
+	})
+	t.Run("Trash OK Case - Per user", func(t *testing.T) {
+		trash, err := repo.Trash(ctx, 1)
+		assert.NoError(t, err)
+		if assert.Len(t, trash, 1) {
+			assert.Equal(t, deleted, trash[0].ID)
+			assert.False(t, trash[0].DeletedAt.IsZero())
+		}
+
+		trash, err = repo.Trash(ctx, 2)
+		assert.NoError(t, err)
+		assert.Empty(t, trash)
+
+		trash, err = repo.Trash(ctx, 0)
+		assert.NoError(t, err)
+		assert.Len(t, trash, 1)
+	})
+	t.Run("Undelete OK Case", func(t *testing.T) {
+		assert.NoError(t, repo.Undelete(ctx, deleted))
+		snippet, err := repo.Get(ctx, deleted)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"trash"}, snippet.Tags)
+		assert.Equal(t, models.ErrNoRecord, repo.Undelete(ctx, deleted))
+	})
+	t.Run("Purge OK Case", func(t *testing.T) {
+		assert.Equal(t, models.ErrNoRecord, repo.Purge(ctx, kept))
+
+		assert.NoError(t, repo.Delete(ctx, deleted))
+		assert.NoError(t, repo.Purge(ctx, deleted))
+		trash, err := repo.Trash(ctx, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, trash)
+	})
+	t.Run("PurgeDeleted OK Case", func(t *testing.T) {
+		assert.NoError(t, repo.Delete(ctx, kept))
+
+		n, err := repo.PurgeDeleted(ctx, time.Now().Add(-time.Hour), 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+
+		n, err = repo.PurgeDeleted(ctx, time.Now().Add(time.Hour), 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, models.ErrNoRecord, repo.Undelete(ctx, kept))
+	})
+}
+
+func TestSQLiteForks(t *testing.T) {
+	db := newSQLiteDB(t)
+	repo, err := sqlite.NewSnippetModel(db, infoLog, errorLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	original, err := repo.Insert(ctx, 1, "Original", "Content", "go", models.Public, "secret", days(7), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.SetTags(ctx, original, []string{"go"}))
+
+	fork, err := repo.Fork(ctx, original, 2)
+	assert.NoError(t, err)
+	snippet, err := repo.Get(ctx, fork)
+	assert.NoError(t, err)
+	assert.Equal(t, "Original", snippet.Title)
+	assert.Equal(t, "go", snippet.Language)
+	assert.Equal(t, 2, snippet.UserID)
+	assert.Equal(t, original, snippet.ForkedFrom)
+	assert.Equal(t, []string{"go"}, snippet.Tags)
+	assert.False(t, snippet.Protected())
+	revisions, err := repo.Revisions(ctx, fork)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 1)
+
+	unlisted, err := repo.Fork(ctx, original, 2)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Update(ctx, unlisted, 2, "Unlisted", "Content", "go", models.Unlisted, days(7)))
+
+	forks, err := repo.Forks(ctx, original)
+	assert.NoError(t, err)
+	if assert.Len(t, forks, 1) {
+		assert.Equal(t, fork, forks[0].ID)
+	}
+
+	_, err = repo.Fork(ctx, 100, 2)
+	assert.Equal(t, models.ErrNoRecord, err)
+
+	// Purging the original keeps the forks.
+	assert.NoError(t, repo.Delete(ctx, original))
+	assert.NoError(t, repo.Purge(ctx, original))
+	snippet, err = repo.Get(ctx, fork)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, snippet.ForkedFrom)
+}
+
+func TestSQLiteListPagination(t *testing.T) {
+	db := newSQLiteDB(t)
+	repo, err := sqlite.NewSnippetModel(db, infoLog, errorLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	for i := 0; i < 5; i++ {
+		_, err := repo.Insert(ctx, 0, "Title", "Content", "", models.Public, "", days(1), 0)
+		assert.NoError(t, err)
+	}
+
+	ids := []int{}
+	opts := models.ListOptions{Limit: 2}
+	for pages := 0; pages < 5; pages++ {
+		page, err := repo.List(ctx, opts)
+		assert.NoError(t, err)
+		for _, s := range page.Snippets {
+			ids = append(ids, s.ID)
+		}
+		if page.Next == nil {
+			break
+		}
+		opts.After = page.Next
+	}
+	assert.Equal(t, []int{5, 4, 3, 2, 1}, ids)
+}
+
+func TestSQLiteSearch(t *testing.T) {
+	db := newSQLiteDB(t)
+	repo, err := sqlite.NewSnippetModel(db, infoLog, errorLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	for _, s := range []struct {
+		title, content string
+		expires        int
+	}{
+		{"Go channels", "Buffered and unbuffered", 7},
+		{"Discounts", "Save 100% today", 7},
+		{"Discounts", "Save 1000 today", 7},
+		{"Old channels", "Buffered", 0},
+	} {
+		_, err := repo.Insert(ctx, 0, s.title, s.content, "", models.Public, "", days(s.expires), 0)
+		assert.NoError(t, err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"Every word must match", "go buffered", 1},
+		{"Case is ignored, expired skipped", "CHANNELS", 1},
+		{"Wildcards are escaped", "100%", 1},
+		{"Empty query", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.Search(ctx, tt.query, models.ListOptions{})
+			assert.NoError(t, err)
+			assert.Len(t, page.Snippets, tt.want)
+		})
+	}
+}
+
+func TestSQLiteTags(t *testing.T) {
+	db := newSQLiteDB(t)
+	repo, err := sqlite.NewSnippetModel(db, infoLog, errorLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	first, err := repo.Insert(ctx, 0, "First", "Content", "", models.Public, "", days(7), 0)
+	assert.NoError(t, err)
+	second, err := repo.Insert(ctx, 0, "Second", "Content", "", models.Public, "", days(7), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.SetTags(ctx, first, []string{"web", "go"}))
+	assert.NoError(t, repo.SetTags(ctx, second, []string{"go", "go_1.22"}))
+
+	t.Run("Get OK Case - Sorted by name", func(t *testing.T) {
+		snippet, err := repo.Get(ctx, first)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"go", "web"}, snippet.Tags)
+	})
+	t.Run("List OK Case - Tag filter", func(t *testing.T) {
+		page, err := repo.List(ctx, models.ListOptions{Tag: "web"})
+		assert.NoError(t, err)
+		assert.Len(t, page.Snippets, 1)
+		assert.Equal(t, first, page.Snippets[0].ID)
+	})
+	t.Run("SuggestTags OK Case - Most used first, wildcards escaped", func(t *testing.T) {
+		tags, err := repo.SuggestTags(ctx, "go", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"go", "go_1.22"}, tags)
+
+		tags, err = repo.SuggestTags(ctx, "go_", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"go_1.22"}, tags)
+	})
+	t.Run("SetTags OK Case - Replaced", func(t *testing.T) {
+		assert.NoError(t, repo.SetTags(ctx, first, nil))
+		snippet, err := repo.Get(ctx, first)
+		assert.NoError(t, err)
+		assert.Empty(t, snippet.Tags)
+
+		tags, err := repo.SuggestTags(ctx, "w", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, tags)
+	})
+	t.Run("SetTags NOK Case - No Record", func(t *testing.T) {
+		assert.Equal(t, models.ErrNoRecord, repo.SetTags(ctx, 100, []string{"go"}))
+	})
+}
+
+func TestSQLiteVisibility(t *testing.T) {
+	db := newSQLiteDB(t)
+	repo, err := sqlite.NewSnippetModel(db, infoLog, errorLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	public, err := repo.Insert(ctx, 0, "Public", "Content", "", models.Public, "", days(7), 0)
+	assert.NoError(t, err)
+	unlisted, err := repo.Insert(ctx, 0, "Unlisted", "Content", "", models.Unlisted, "", days(7), 0)
+	assert.NoError(t, err)
+	private, err := repo.Insert(ctx, 0, "Private", "Content", "", models.Private, "", days(7), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.SetTags(ctx, private, []string{"secret"}))
+
+	t.Run("List OK Case - Only public snippets", func(t *testing.T) {
+		page, err := repo.List(ctx, models.ListOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, page.Snippets, 1)
+		assert.Equal(t, public, page.Snippets[0].ID)
+
+		page, err = repo.Search(ctx, "content", models.ListOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, page.Snippets, 1)
+
+		tags, err := repo.SuggestTags(ctx, "s", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, tags)
+	})
+	t.Run("BySlug OK Case", func(t *testing.T) {
+		snippet, err := repo.Get(ctx, unlisted)
+		assert.NoError(t, err)
+		assert.Equal(t, models.Unlisted, snippet.Visibility)
+		assert.Len(t, snippet.Slug, 16)
+
+		bySlug, err := repo.BySlug(ctx, snippet.Slug)
+		assert.NoError(t, err)
+		assert.Equal(t, unlisted, bySlug.ID)
+		assert.Equal(t, "/s/"+snippet.Slug, bySlug.Path())
+	})
+	t.Run("BySlug NOK Case - No Record", func(t *testing.T) {
+		_, err := repo.BySlug(ctx, "nope")
+		assert.Equal(t, models.ErrNoRecord, err)
+	})
+	t.Run("Insert OK Case - Password", func(t *testing.T) {
+		id, err := repo.Insert(ctx, 0, "Protected", "Content", "", models.Public, "s3cret", days(7), 0)
+		assert.NoError(t, err)
+
+		snippet, err := repo.Get(ctx, id)
+		assert.NoError(t, err)
+		assert.True(t, snippet.Protected())
+		assert.True(t, snippet.PasswordMatches("s3cret"))
+		assert.False(t, snippet.PasswordMatches("secret"))
+
+		snippet, err = repo.Get(ctx, public)
+		assert.NoError(t, err)
+		assert.False(t, snippet.Protected())
+		assert.False(t, snippet.PasswordMatches(""))
+	})
+	t.Run("Update OK Case - The slug is kept", func(t *testing.T) {
+		snippet, err := repo.Get(ctx, private)
+		assert.NoError(t, err)
+		assert.Empty(t, snippet.Slug)
+
+		assert.NoError(t, repo.Update(ctx, private, 0, "Private", "Content", "", models.Unlisted, days(7)))
+		snippet, err = repo.Get(ctx, private)
+		assert.NoError(t, err)
+		slug := snippet.Slug
+		assert.NotEmpty(t, slug)
+
+		assert.NoError(t, repo.Update(ctx, private, 0, "Private", "Content", "", models.Unlisted, days(7)))
+		snippet, err = repo.Get(ctx, private)
+		assert.NoError(t, err)
+		assert.Equal(t, slug, snippet.Slug)
+	})
+	t.Run("View OK Case - Burned after the last view", func(t *testing.T) {
+		id, err := repo.Insert(ctx, 0, "Burn", "Content", "", models.Unlisted, "", days(7), 2)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.SetTags(ctx, id, []string{"go"}))
+		snippet, err := repo.Get(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, snippet.ViewsLeft)
+		slug := snippet.Slug
+
+		snippet, err = repo.View(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, snippet.ViewsLeft)
+		assert.False(t, snippet.Burned)
+
+		snippet, err = repo.View(ctx, id)
+		assert.NoError(t, err)
+		assert.True(t, snippet.Burned)
+		assert.Equal(t, "Content", snippet.Content)
+		assert.Equal(t, []string{"go"}, snippet.Tags)
+
+		_, err = repo.View(ctx, id)
+		assert.Equal(t, models.ErrNoRecord, err)
+		_, err = repo.Get(ctx, id)
+		assert.Equal(t, models.ErrNoRecord, err)
+
+		burned, err := repo.Burned(ctx, id, "")
+		assert.NoError(t, err)
+		assert.True(t, burned)
+		burned, err = repo.Burned(ctx, 0, slug)
+		assert.NoError(t, err)
+		assert.True(t, burned)
+		burned, err = repo.Burned(ctx, public, "")
+		assert.NoError(t, err)
+		assert.False(t, burned)
+	})
+	t.Run("View OK Case - Unlimited snippets are kept", func(t *testing.T) {
+		snippet, err := repo.View(ctx, public)
+		assert.NoError(t, err)
+		assert.False(t, snippet.Burned)
+		assert.Equal(t, 0, snippet.ViewsLeft)
+		_, err = repo.Get(ctx, public)
+		assert.NoError(t, err)
+	})
+}
+
+func TestSQLiteUserModel(t *testing.T) {
+	db := newSQLiteDB(t)
+	defer db.Close()
+	repo := &sqlite.UserModel{DB: db}
+
+	err := repo.Insert("Name", "name@example.com", "C0mpl3xPass!")
+	assert.NoError(t, err)
+
+	t.Run("Insert NOK Case - Duplicate Email", func(t *testing.T) {
+		err := repo.Insert("Other", "name@example.com", "An0therPass!")
+		assert.Equal(t, models.ErrDuplicateEmail, err)
+	})
+	t.Run("Authenticate OK Case", func(t *testing.T) {
+		id, err := repo.Authenticate("name@example.com", "C0mpl3xPass!")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, id)
+	})
+	t.Run("Authenticate NOK Case - Wrong Password", func(t *testing.T) {
+		_, err := repo.Authenticate("name@example.com", "wrong")
+		assert.Equal(t, models.ErrInvalidCredentials, err)
+	})
+	t.Run("Get OK Case", func(t *testing.T) {
+		user, err := repo.Get(1)
+		assert.NoError(t, err)
+		assert.Equal(t, "name@example.com", user.Email)
+	})
+	t.Run("Get NOK Case - No Record", func(t *testing.T) {
+		user, err := repo.Get(2)
+		assert.Nil(t, user)
+		assert.Equal(t, models.ErrNoRecord, err)
+	})
+}
+
+func TestSQLiteTokenModel(t *testing.T) {
+	db := newSQLiteDB(t)
+	defer db.Close()
+	users := &sqlite.UserModel{DB: db}
+	assert.NoError(t, users.Insert("Name", "name@example.com", "C0mpl3xPass!"))
+	repo := &sqlite.TokenModel{DB: db}
+
+	token, err := repo.Insert(ctx, 1, "Script", models.ScopeRead)
+	assert.NoError(t, err)
+
+	t.Run("Authenticate OK Case", func(t *testing.T) {
+		tok, err := repo.Authenticate(ctx, token)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, tok.UserID)
+		assert.Equal(t, models.ScopeRead, tok.Scope)
+	})
+	t.Run("Authenticate NOK Case - Unknown Token", func(t *testing.T) {
+		_, err := repo.Authenticate(ctx, "sb_unknown")
+		assert.Equal(t, models.ErrInvalidCredentials, err)
+	})
+	t.Run("ByUser OK Case - Records Last Use", func(t *testing.T) {
+		tokens, err := repo.ByUser(ctx, 1)
+		assert.NoError(t, err)
+		if assert.Len(t, tokens, 1) {
+			assert.Equal(t, "Script", tokens[0].Name)
+			assert.False(t, tokens[0].LastUsed.IsZero())
+		}
+	})
+	t.Run("Only The Hash Is Stored", func(t *testing.T) {
+		var hash string
+		assert.NoError(t, db.QueryRow("SELECT hashed_token FROM api_tokens").Scan(&hash))
+		assert.Equal(t, models.HashToken(token), hash)
+		assert.NotEqual(t, token, hash)
+	})
+	t.Run("Revoke NOK Case - Other User", func(t *testing.T) {
+		assert.Equal(t, models.ErrNoRecord, repo.Revoke(ctx, 1, 2))
+	})
+	t.Run("Revoke OK Case", func(t *testing.T) {
+		assert.NoError(t, repo.Revoke(ctx, 1, 1))
+		_, err := repo.Authenticate(ctx, token)
+		assert.Equal(t, models.ErrInvalidCredentials, err)
+	})
+}
@@ -2,6 +2,7 @@ package test
 
 import (
 	"snippetbox/cmd/server"
+	"snippetbox/pkg/models"
 	"testing"
 	"time"
 )
@@ -22,6 +23,11 @@ func TestHumanDate(t *testing.T) {
 			tm:   time.Time{},
 			want: "",
 		},
+		{
+			name: "Never",
+			tm:   models.Never,
+			want: "Never",
+		},
 		{
 			name: "CET",
 			tm:   time.Date(2020, 12, 17, 10, 0, 0, 0, time.FixedZone("CET", 1*60*60)),
@@ -39,3 +45,127 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{
+			name:  "Case is ignored",
+			text:  "Go is fun, go!",
+			terms: []string{"go"},
+			want:  "<mark>Go</mark> is fun, <mark>go</mark>!",
+		},
+		{
+			name:  "Text is escaped",
+			text:  "<b>bold</b>",
+			terms: []string{"b"},
+			want:  "&lt;<mark>b</mark>&gt;<mark>b</mark>old&lt;/<mark>b</mark>&gt;",
+		},
+		{
+			name:  "Longest term wins",
+			text:  "snippets",
+			terms: []string{"snippet", "snippets"},
+			want:  "<mark>snippets</mark>",
+		},
+		{
+			name:  "No terms",
+			text:  "a < b",
+			terms: nil,
+			want:  "a &lt; b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(server.Highlight(tt.text, tt.terms))
+
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{
+			name:  "Short text is kept",
+			text:  "short",
+			terms: []string{"x"},
+			want:  "short",
+		},
+		{
+			name:  "No match starts at the beginning",
+			text:  "abcdefghij",
+			terms: []string{"x"},
+			want:  "abcdef…",
+		},
+		{
+			name:  "Around the match",
+			text:  "abcdefghijklmnop",
+			terms: []string{"k"},
+			want:  "…ijklmn…",
+		},
+		{
+			name:  "Match at the end",
+			text:  "abcdefghij",
+			terms: []string{"j"},
+			want:  "…efghij",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := server.Excerpt(tt.text, tt.terms, 6)
+
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHighlightCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		language string
+		want     string
+	}{
+		{
+			name:     "Numbered and escaped",
+			code:     "a < b\r\nc\n",
+			language: "plaintext",
+			want: "<pre class='code'><code>" +
+				"<span class='line' id='L1'><a href='#L1'>1</a>a &lt; b</span>\n" +
+				"<span class='line' id='L2'><a href='#L2'>2</a>c</span></code></pre>",
+		},
+		{
+			name:     "Tokens split across lines",
+			code:     "/* a\nb */ x",
+			language: "go",
+			want: "<pre class='code'><code>" +
+				"<span class='line' id='L1'><a href='#L1'>1</a><span class='comment'>/* a</span></span>\n" +
+				"<span class='line' id='L2'><a href='#L2'>2</a><span class='comment'>b */</span> x</span></code></pre>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(server.HighlightCode(tt.code, tt.language))
+
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package test
+
+import (
+	"snippetbox/cmd/server"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottle(t *testing.T) {
+	t.Run("Refused after too many failures", func(t *testing.T) {
+		throttle := server.NewThrottle(2, time.Hour)
+		assert.True(t, throttle.Allow(1))
+		throttle.Fail(1)
+		assert.True(t, throttle.Allow(1))
+		throttle.Fail(1)
+		assert.False(t, throttle.Allow(1))
+		// Every key is counted on its own.
+		assert.True(t, throttle.Allow(2))
+	})
+	t.Run("Old failures are forgotten", func(t *testing.T) {
+		throttle := server.NewThrottle(1, time.Millisecond)
+		throttle.Fail(1)
+		time.Sleep(5 * time.Millisecond)
+		assert.True(t, throttle.Allow(1))
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("Refused after too many requests", func(t *testing.T) {
+		limiter := server.NewRateLimiter(2, time.Hour)
+		assert.True(t, limiter.Allow("192.0.2.1"))
+		assert.True(t, limiter.Allow("192.0.2.1"))
+		assert.False(t, limiter.Allow("192.0.2.1"))
+		// Every key is counted on its own.
+		assert.True(t, limiter.Allow("192.0.2.2"))
+	})
+	t.Run("Old requests are forgotten", func(t *testing.T) {
+		limiter := server.NewRateLimiter(1, time.Millisecond)
+		assert.True(t, limiter.Allow("192.0.2.1"))
+		time.Sleep(5 * time.Millisecond)
+		assert.True(t, limiter.Allow("192.0.2.1"))
+	})
+	t.Run("Keys are dropped once their window passed", func(t *testing.T) {
+		limiter := server.NewRateLimiter(1, time.Millisecond)
+		for _, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+			assert.True(t, limiter.Allow(ip))
+		}
+		assert.Equal(t, 3, limiter.Len())
+		time.Sleep(5 * time.Millisecond)
+		assert.True(t, limiter.Allow("192.0.2.4"))
+		assert.Equal(t, 1, limiter.Len())
+	})
+}
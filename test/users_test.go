@@ -150,16 +150,16 @@ func TestUsers(t *testing.T) {
 		userModel := &mysql.UserModel{DB: db}
 		id := 1
 		rows := sqlmock.NewRows([]string{
-			"id", "name", "email", "created"})
+			"id", "name", "email", "created", "admin"})
 		timeCreated, err := time.Parse(time.RFC3339, "2024-02-23T10:23:42Z")
 		if err != nil {
 			fmt.Printf("parsing time failed")
 		}
 
 		rows.AddRow(
-			id, "Jonas", "jonas@email.com", timeCreated)
+			id, "Jonas", "jonas@email.com", timeCreated, false)
 		mock.ExpectQuery(
-			"SELECT id, name, email, created FROM users WHERE id \\= \\?").
+			"SELECT id, name, email, created, admin FROM users WHERE id \\= \\?").
 			WithArgs(id).WillReturnRows(rows)
 		modelsUser, newErr := userModel.Get(id)
 		assert.NoError(t, newErr)
@@ -171,7 +171,7 @@ func TestUsers(t *testing.T) {
 		id := 1
 
 		mock.ExpectQuery(
-			"SELECT id, name, email, created FROM users WHERE id \\= \\?").
+			"SELECT id, name, email, created, admin FROM users WHERE id \\= \\?").
 			WithArgs(id).WillReturnError(sql.ErrNoRows)
 		modelsUser, newErr := userModel.Get(id)
 		assert.Error(t, newErr)
@@ -183,7 +183,7 @@ func TestUsers(t *testing.T) {
 		id := 1
 
 		mock.ExpectQuery(
-			"SELECT id, name, email, created FROM users WHERE id \\= \\?").
+			"SELECT id, name, email, created, admin FROM users WHERE id \\= \\?").
 			WithArgs(id).WillReturnError(models.ErrInvalidCredentials)
 		modelsUser, newErr := userModel.Get(id)
 		assert.Error(t, newErr)
@@ -5,4 +5,30 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+// Suggests existing tags for the last tag being typed. The suggestions
+// keep the tags typed before it, since picking one replaces the value.
+var tagInputs = document.querySelectorAll("input[name='tags'][list]");
+for (var i = 0; i < tagInputs.length; i++) {
+	tagInputs[i].addEventListener("input", function (event) {
+		var input = event.target;
+		var list = document.getElementById(input.getAttribute("list"));
+		var parts = input.value.match(/^(.*[,\s])?([^,\s]*)$/);
+		var head = parts[1] || "";
+		var prefix = parts[2].toLowerCase();
+		if (prefix == "") {
+			list.innerHTML = "";
+			return;
+		}
+		fetch("/tags/suggest?prefix=" + encodeURIComponent(prefix))
+			.then(function (response) { return response.json(); })
+			.then(function (tags) {
+				list.innerHTML = "";
+				tags.forEach(function (tag) {
+					var option = document.createElement("option");
+					option.value = head + tag;
+					list.appendChild(option);
+				});
+			});
+	});
+}